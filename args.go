@@ -0,0 +1,340 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUsage is matched by errors returned when a command's arguments or
+// flags are invalid.
+var ErrUsage = errors.New("usage error")
+
+// ArgType is the type of an argument or flag value.
+type ArgType int
+
+// Argument types.
+const (
+	TypeString ArgType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeDuration
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeDuration:
+		return "duration"
+	}
+
+	return "string"
+}
+
+// parse converts s to a value of the type.
+func (t ArgType) parse(s string) (v any, err error) {
+	switch t {
+	case TypeInt:
+		var i int64
+		i, err = strconv.ParseInt(s, 0, 0)
+		v = int(i)
+	case TypeFloat:
+		v, err = strconv.ParseFloat(s, 64)
+	case TypeBool:
+		v, err = strconv.ParseBool(s)
+	case TypeDuration:
+		v, err = time.ParseDuration(s)
+	default:
+		v = s
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid %s", t)
+	}
+
+	return
+}
+
+// Flag describes a command flag.  Flags are given as --name or -short and
+// non-bool flags take a value, either as the following word or joined with
+// "=".  Flags may appear anywhere before a "--" word.
+type Flag struct {
+	Name        string   // Name is the long name, given as --name
+	Short       string   // Short is an optional one letter name, given as -s
+	Description string   // Description describes the flag
+	Type        ArgType  // Type is the value type
+	Default     string   // Default is the value used when the flag is omitted
+	Choices     []string // Choices optionally restricts the allowed values
+	Sensitive   bool     // Sensitive values are redacted from audit records
+}
+
+// UsageError is returned by Exec when a command's arguments or flags are
+// invalid.  It matches ErrUsage with errors.Is.
+type UsageError struct {
+	Command string // Command is the command name
+	Usage   string // Usage is the command usage line
+	Index   int    // Index is the offending argument index or -1 if missing
+	Token   string // Token is the offending argument
+	Msg     string // Msg describes the problem
+}
+
+func (e *UsageError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %s", e.Command, e.Msg)
+	}
+
+	return fmt.Sprintf("%s: argument %d %q: %s", e.Command, e.Index+1, e.Token, e.Msg)
+}
+
+func (e *UsageError) Is(target error) bool { return target == ErrUsage }
+
+// Values holds the converted arguments and flags of a command keyed by name.
+// Variadic arguments are held as a slice.
+type Values map[string]any
+
+type valuesKey struct{}
+
+// ValuesFrom returns the converted arguments and flags of the running
+// command.  It is empty for commands that do not declare any.
+func ValuesFrom(ctx context.Context) Values {
+	v, _ := ctx.Value(valuesKey{}).(Values)
+	return v
+}
+
+// String returns the named string value.
+func (v Values) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+// Int returns the named int value.
+func (v Values) Int(name string) int {
+	i, _ := v[name].(int)
+	return i
+}
+
+// Float returns the named float value.
+func (v Values) Float(name string) float64 {
+	f, _ := v[name].(float64)
+	return f
+}
+
+// Bool returns the named bool value.
+func (v Values) Bool(name string) bool {
+	b, _ := v[name].(bool)
+	return b
+}
+
+// Duration returns the named duration value.
+func (v Values) Duration(name string) time.Duration {
+	d, _ := v[name].(time.Duration)
+	return d
+}
+
+// List returns the named variadic values.
+func (v Values) List(name string) []any {
+	l, _ := v[name].([]any)
+	return l
+}
+
+// Has reports whether the named value was given or has a default.
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// declared reports whether the command declares arguments or flags, in
+// which case Exec validates them.
+func (c Command) declared() bool {
+	return len(c.Args) > 0 || len(c.Flags) > 0
+}
+
+// usage returns the command usage line, generating it from the declared
+// arguments and flags if one is not set.
+func (c Command) usage(name string) string {
+	if c.Usage != "" {
+		return c.Usage
+	}
+
+	usage := name
+	if len(c.Flags) > 0 {
+		usage += " [flags]"
+	}
+	for _, a := range c.Args {
+		n := a.Name
+		if a.Variadic {
+			n += "..."
+		}
+		if a.Required {
+			usage += " <" + n + ">"
+		} else {
+			usage += " [" + n + "]"
+		}
+	}
+
+	return usage
+}
+
+// parse validates and converts args according to the declared arguments and
+// flags.  It returns the converted values and the positional arguments with
+// flags removed and defaults filled in, aligned with their declarations.
+func (c Command) parse(name string, args []string) (Values, []string, error) {
+	vals := make(Values)
+	usageErr := func(i int, msg string, a ...any) error {
+		e := &UsageError{Command: name, Usage: c.usage(name), Index: i, Msg: fmt.Sprintf(msg, a...)}
+		if i >= 0 {
+			e.Token = args[i]
+		}
+		return e
+	}
+	check := func(typ ArgType, choices []string, i int, s string) (any, error) {
+		if len(choices) > 0 && !slices.Contains(choices, s) {
+			return nil, usageErr(i, "must be one of %s", strings.Join(choices, ", "))
+		}
+		v, err := typ.parse(s)
+		if err != nil {
+			return nil, usageErr(i, "%v", err)
+		}
+		return v, nil
+	}
+
+	// Separate flags from positional arguments.
+	var pos []string
+	var posIdx []int
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" && len(c.Flags) > 0 {
+			for j := i + 1; j < len(args); j++ {
+				pos, posIdx = append(pos, args[j]), append(posIdx, j)
+			}
+			break
+		}
+
+		f, val, hasVal, isFlag := c.flag(a)
+		if !isFlag {
+			pos, posIdx = append(pos, a), append(posIdx, i)
+			continue
+		}
+		if f == nil {
+			return nil, nil, usageErr(i, "unknown flag")
+		}
+
+		vi := i
+		switch {
+		case hasVal:
+		case f.Type == TypeBool:
+			val = "true"
+		case i+1 < len(args):
+			i++
+			vi, val = i, args[i]
+		default:
+			return nil, nil, usageErr(i, "flag needs a value")
+		}
+
+		v, err := check(f.Type, f.Choices, vi, val)
+		if err != nil {
+			return nil, nil, err
+		}
+		vals[f.Name] = v
+	}
+
+	for _, f := range c.Flags {
+		if _, ok := vals[f.Name]; !ok && f.Default != "" {
+			v, err := check(f.Type, f.Choices, -1, f.Default)
+			if err != nil {
+				return nil, nil, err
+			}
+			vals[f.Name] = v
+		}
+	}
+
+	// Match positional arguments to their declarations.  Omitted optional
+	// arguments without defaults are passed as "" so later arguments keep
+	// their positions, except at the end where they are dropped.
+	var out []string
+	filled := 0
+	for i, a := range c.Args {
+		if a.Variadic {
+			var list []any
+			for j := i; j < len(pos); j++ {
+				v, err := check(a.Type, a.Choices, posIdx[j], pos[j])
+				if err != nil {
+					return nil, nil, err
+				}
+				list = append(list, v)
+				out = append(out, pos[j])
+				filled = len(out)
+			}
+			if len(list) == 0 && a.Required {
+				return nil, nil, usageErr(-1, "missing %s", a.Name)
+			}
+			vals[a.Name] = list
+			return vals, out[:filled], nil
+		}
+
+		var s string
+		idx := -1
+		switch {
+		case i < len(pos):
+			s, idx = pos[i], posIdx[i]
+		case a.Required:
+			return nil, nil, usageErr(-1, "missing %s", a.Name)
+		case a.Default != "":
+			s = a.Default
+		default:
+			out = append(out, "")
+			continue
+		}
+
+		v, err := check(a.Type, a.Choices, idx, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		vals[a.Name] = v
+		out = append(out, s)
+		filled = len(out)
+	}
+
+	if len(pos) > len(c.Args) {
+		return nil, nil, usageErr(posIdx[len(c.Args)], "too many arguments")
+	}
+
+	return vals, out[:filled], nil
+}
+
+// flag interprets a as a flag.  isFlag is false if it is a positional
+// argument and f is nil if it looks like a flag but none is declared.
+func (c Command) flag(a string) (f *Flag, val string, hasVal, isFlag bool) {
+	if len(c.Flags) == 0 || len(a) < 2 || a[0] != '-' {
+		return
+	}
+
+	long := strings.HasPrefix(a, "--")
+	name := a[1:]
+	if long {
+		name = a[2:]
+	}
+	name, val, hasVal = strings.Cut(name, "=")
+	for i := range c.Flags {
+		if long && c.Flags[i].Name == name || !long && c.Flags[i].Short == name && name != "" {
+			return &c.Flags[i], val, hasVal, true
+		}
+	}
+
+	// A single dash that is not a declared short flag, such as a negative
+	// number, is positional.
+	return nil, "", false, long
+}
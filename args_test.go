@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestArgs(t *testing.T) {
+	var sh Shell
+
+	var vals Values
+	var args []string
+	sh.RegisterCommand(Command{
+		Func: func(ctx context.Context, _ io.ReadWriter, a ...string) error {
+			vals, args = ValuesFrom(ctx), a
+			return nil
+		},
+		Args: []Arg{
+			{Name: "level", Required: true, Choices: []string{"debug", "trace"}},
+			{Name: "count", Type: TypeInt, Default: "10"},
+			{Name: "filters", Variadic: true},
+		},
+		Flags: []Flag{
+			{Name: "every", Short: "e", Type: TypeDuration, Default: "1s"},
+			{Name: "follow", Short: "f", Type: TypeBool},
+			{Name: "scale", Type: TypeFloat},
+		},
+	}, "watch log")
+
+	for _, test := range []struct {
+		input string
+		vals  Values
+		args  []string
+		err   string
+	}{
+		{
+			input: "watch log debug",
+			vals:  Values{"level": "debug", "count": 10, "every": time.Second, "filters": []any(nil)},
+			args:  []string{"debug", "10"},
+		},
+		{
+			input: "wa lo trace -5 a b --every=250ms -f --scale 1.5",
+			vals: Values{"level": "trace", "count": -5, "every": 250 * time.Millisecond,
+				"follow": true, "scale": 1.5, "filters": []any{"a", "b"}},
+			args: []string{"trace", "-5", "a", "b"},
+		},
+		{
+			input: "watch log -e 2m debug 3 -- --every",
+			vals:  Values{"level": "debug", "count": 3, "every": 2 * time.Minute, "filters": []any{"--every"}},
+			args:  []string{"debug", "3", "--every"},
+		},
+		{input: "watch log", err: `watch log: missing level`},
+		{input: "watch log info", err: `watch log: argument 1 "info": must be one of debug, trace`},
+		{input: "watch log debug ten", err: `watch log: argument 2 "ten": invalid int`},
+		{input: "watch log debug --every soon", err: `watch log: argument 3 "soon": invalid duration`},
+		{input: "watch log debug --every", err: `watch log: argument 2 "--every": flag needs a value`},
+		{input: "watch log debug --verbose", err: `watch log: argument 2 "--verbose": unknown flag`},
+		{input: "watch log debug ---follow", err: `watch log: argument 2 "---follow": unknown flag`},
+		{input: "watch log debug ----every=2s", err: `watch log: argument 2 "----every=2s": unknown flag`},
+		{input: "watch log --follow=maybe debug", err: `watch log: argument 1 "--follow=maybe": invalid bool`},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			vals, args = nil, nil
+			err := sh.Exec(context.Background(), nil, test.input)
+			if test.err != "" {
+				if !errors.Is(err, ErrUsage) || err.Error() != test.err {
+					t.Fatalf("err = %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exec error: %v", err)
+			}
+
+			if len(vals) != len(test.vals) {
+				t.Errorf("vals = %v, want %v", vals, test.vals)
+			}
+			for k, v := range test.vals {
+				if l, ok := v.([]any); ok {
+					if !slices.Equal(vals.List(k), l) {
+						t.Errorf("vals[%q] = %v, want %v", k, vals[k], v)
+					}
+				} else if vals[k] != v {
+					t.Errorf("vals[%q] = %v, want %v", k, vals[k], v)
+				}
+			}
+			if !slices.Equal(args, test.args) {
+				t.Errorf("args = %q, want %q", args, test.args)
+			}
+		})
+	}
+}
+
+func TestArgsTooMany(t *testing.T) {
+	var sh Shell
+	sh.RegisterCommand(Command{
+		Func: func(context.Context, io.ReadWriter, ...string) error { return nil },
+		Args: []Arg{{Name: "date"}},
+	}, "archive")
+
+	err := sh.Exec(context.Background(), nil, "archive today --now")
+	var uerr *UsageError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("err = %v, want *UsageError", err)
+	}
+	if uerr.Index != 1 || uerr.Token != "--now" || uerr.Usage != "archive [date]" {
+		t.Errorf("usage error = %+v", uerr)
+	}
+}
+
+func TestArgsOmittedOptional(t *testing.T) {
+	var sh Shell
+	var got []string
+	sh.RegisterCommand(Command{
+		Func: func(_ context.Context, _ io.ReadWriter, args ...string) error {
+			got = args
+			return nil
+		},
+		Args: []Arg{{Name: "a"}, {Name: "b", Default: "x"}, {Name: "c"}},
+	}, "cmd")
+
+	for _, test := range []struct {
+		input string
+		args  []string
+	}{
+		{"cmd", []string{"", "x"}},
+		{"cmd 1", []string{"1", "x"}},
+		{"cmd 1 2 3", []string{"1", "2", "3"}},
+	} {
+		if err := sh.Exec(context.Background(), nil, test.input); err != nil {
+			t.Fatalf("Exec(%q) error: %v", test.input, err)
+		}
+		if !slices.Equal(got, test.args) {
+			t.Errorf("Exec(%q) args %q, want %q", test.input, got, test.args)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces the values of sensitive arguments and flags in audit
+// records.
+const Redacted = "***"
+
+// AuditRecord records the execution of a command.
+type AuditRecord struct {
+	Principal Principal     // Principal is the principal carried by the context
+	Command   string        // Command is the resolved command execution string
+	Args      []string      // Args are the arguments with sensitive values redacted
+	Start     time.Time     // Start is when the command started
+	Duration  time.Duration // Duration is how long the command ran
+	Err       error         // Err is the error the command returned
+}
+
+// AuditSink receives a record of each command executed.  It must be safe for
+// concurrent use.
+type AuditSink interface {
+	Audit(r AuditRecord)
+}
+
+// RedactFunc returns a copy of the arguments of a command with any sensitive
+// values replaced, typically by Redacted.  The arguments have already had
+// those of sensitive Args and Flags redacted.
+type RedactFunc func(cmd string, args []string) []string
+
+// auditor is the auditing configuration of a shell.
+type auditor struct {
+	sink   AuditSink
+	redact RedactFunc
+}
+
+// SetAuditSink sets the AuditSink that Exec records each command it resolves
+// to, including those the principal is not authorized to run.  Commands that
+// are not found or are ambiguous are not recorded.  A nil AuditSink, the
+// default, disables auditing.
+func (sh *Shell) SetAuditSink(s AuditSink) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	a := auditor{sink: s}
+	if p := sh.audit.Load(); p != nil {
+		a.redact = p.redact
+	}
+	sh.audit.Store(&a)
+}
+
+// SetRedactor sets a function which redacts the arguments of audited
+// commands in addition to those of sensitive Args and Flags.  It's useful
+// for commands which don't declare their arguments, such as those added
+// with Register.
+func (sh *Shell) SetRedactor(f RedactFunc) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	a := auditor{redact: f}
+	if p := sh.audit.Load(); p != nil {
+		a.sink = p.sink
+	}
+	sh.audit.Store(&a)
+}
+
+// record sends the execution of a command to the AuditSink, if there is one.
+// The arguments are redacted, as are those included in a *UsageError or
+// *PanicError it returned.  Errors wrapping them, such as by middleware,
+// are recorded as is.
+func (sh *Shell) record(ctx context.Context, m match, args []string, start time.Time, err error) {
+	a := sh.audit.Load()
+	if a == nil || a.sink == nil {
+		return
+	}
+
+	if errors.Is(err, ErrCmdQuit) {
+		err = nil
+	}
+	red := m.cmd.redact(args)
+	if a.redact != nil {
+		red = a.redact(m.name, red)
+	}
+	principal, _ := PrincipalFrom(ctx)
+	a.sink.Audit(AuditRecord{
+		Principal: principal,
+		Command:   m.name,
+		Args:      red,
+		Start:     start,
+		Duration:  time.Since(start),
+		Err:       redactErr(err, args, red),
+	})
+}
+
+// redactErr returns err with the arguments it includes replaced by their
+// redacted values.
+func redactErr(err error, args, red []string) error {
+	switch e := err.(type) {
+	case *UsageError:
+		if e.Index >= 0 && e.Index < len(red) && red[e.Index] != args[e.Index] {
+			c := *e
+			c.Token = red[e.Index]
+			return &c
+		}
+	case *PanicError:
+		c := *e
+		c.Args = red
+		return &c
+	}
+
+	return err
+}
+
+// redact returns a copy of args with the values of sensitive arguments and
+// flags replaced by Redacted.
+func (c Command) redact(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+
+	// positional redacts the next positional argument, at i, if its
+	// declaration is sensitive.
+	pos := 0
+	positional := func(i int) {
+		a := pos
+		if n := len(c.Args); a >= n && n > 0 && c.Args[n-1].Variadic {
+			a = n - 1
+		}
+		if a < len(c.Args) && c.Args[a].Sensitive {
+			out[i] = Redacted
+		}
+		pos++
+	}
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" && len(c.Flags) > 0 {
+			for j := i + 1; j < len(args); j++ {
+				positional(j)
+			}
+			break
+		}
+
+		f, _, hasVal, isFlag := c.flag(args[i])
+		switch {
+		case !isFlag:
+			positional(i)
+		case f == nil || !f.Sensitive:
+			if f != nil && !hasVal && f.Type != TypeBool {
+				i++
+			}
+		case hasVal:
+			name, _, _ := strings.Cut(args[i], "=")
+			out[i] = name + "=" + Redacted
+		case f.Type != TypeBool && i+1 < len(args):
+			i++
+			out[i] = Redacted
+		}
+	}
+
+	return out
+}
+
+// AuditWriter is an AuditSink which writes records to an io.Writer in JSON
+// Lines format, one JSON object per line.
+type AuditWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewAuditWriter creates a new AuditWriter writing to w.
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{w: w}
+}
+
+// auditJSON is the JSON encoding of an AuditRecord.
+type auditJSON struct {
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	Duration  string    `json:"duration"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Audit writes a record.
+func (aw *AuditWriter) Audit(r AuditRecord) {
+	j := auditJSON{
+		Time:      r.Start,
+		Principal: r.Principal.Name,
+		Roles:     r.Principal.Roles,
+		Command:   r.Command,
+		Args:      r.Args,
+		Duration:  r.Duration.String(),
+	}
+	if j.Args == nil {
+		j.Args = []string{}
+	}
+	if r.Err != nil {
+		j.Error = r.Err.Error()
+	}
+	b, err := json.Marshal(j)
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if err == nil {
+		_, err = aw.w.Write(append(b, '\n'))
+	}
+	if aw.err == nil {
+		aw.err = err
+	}
+}
+
+// Err returns the first error encountered writing a record.
+func (aw *AuditWriter) Err() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return aw.err
+}
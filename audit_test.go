@@ -0,0 +1,182 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink records audit records.
+type testSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (ts *testSink) Audit(r AuditRecord) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.records = append(ts.records, r)
+}
+
+func TestAudit(t *testing.T) {
+	errTest := errors.New("test")
+	sh := testShell()
+	sh.RegisterCommand(Command{
+		Func: func(context.Context, io.ReadWriter, ...string) error {
+			time.Sleep(time.Millisecond)
+			return errTest
+		},
+		Args: []Arg{{Name: "user"}, {Name: "password", Sensitive: true}},
+	}, "login")
+	sh.RegisterCommand(Command{
+		Func:        func(context.Context, io.ReadWriter, ...string) error { return nil },
+		Permissions: []string{"lamps"},
+	}, "lamps on")
+	sh.SetAuthorizer(Roles{"operator": {"lamps"}})
+
+	var ts testSink
+	sh.SetAuditSink(&ts)
+
+	ctx := WithPrincipal(context.Background(), Principal{Name: "joe", Roles: []string{"viewer"}})
+	before := time.Now()
+	for _, s := range []string{"login joe secret", "la on", "xyzzy", "up"} {
+		sh.Exec(ctx, nil, s)
+	}
+
+	if len(ts.records) != 3 {
+		t.Fatalf("%d records, want 3: %+v", len(ts.records), ts.records)
+	}
+	for i, want := range []struct {
+		cmd  string
+		args []string
+		err  error
+	}{
+		{"login", []string{"joe", Redacted}, errTest},
+		{"lamps on", []string{}, ErrPermission},
+		{"uptime", []string{}, nil},
+	} {
+		r := ts.records[i]
+		if r.Command != want.cmd || !slices.Equal(r.Args, want.args) || !errors.Is(r.Err, want.err) {
+			t.Errorf("record %d = %+v, want %s %q %v", i, r, want.cmd, want.args, want.err)
+		}
+		if r.Principal.Name != "joe" || r.Start.Before(before) {
+			t.Errorf("record %d principal %+v start %v", i, r.Principal, r.Start)
+		}
+	}
+	if ts.records[0].Duration < time.Millisecond {
+		t.Errorf("record duration %v, want at least 1ms", ts.records[0].Duration)
+	}
+}
+
+func TestAuditRedactErr(t *testing.T) {
+	sh := testShell()
+	sh.RegisterCommand(Command{
+		Func: func(context.Context, io.ReadWriter, ...string) error { return nil },
+		Args: []Arg{{Name: "pin", Type: TypeInt, Sensitive: true}},
+	}, "login")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error {
+		panic("bad key")
+	}, "unlock")
+	sh.SetRedactor(func(cmd string, args []string) []string {
+		if cmd != "unlock" {
+			return args
+		}
+		return slices.Repeat([]string{Redacted}, len(args))
+	})
+
+	var ts testSink
+	sh.SetAuditSink(&ts)
+	for _, s := range []string{"login hunter2", "unlock s3cret"} {
+		sh.Exec(context.Background(), nil, s)
+	}
+	if len(ts.records) != 2 {
+		t.Fatalf("%d records, want 2", len(ts.records))
+	}
+
+	var buf bytes.Buffer
+	aw := NewAuditWriter(&buf)
+	for _, r := range ts.records {
+		aw.Audit(r)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "s3cret") {
+		t.Errorf("audit log includes sensitive values:\n%s", buf.String())
+	}
+	if !errors.Is(ts.records[0].Err, ErrUsage) || !strings.Contains(ts.records[0].Err.Error(), Redacted) {
+		t.Errorf("record error = %v, want a redacted usage error", ts.records[0].Err)
+	}
+	var pe *PanicError
+	if !errors.As(ts.records[1].Err, &pe) || !slices.Equal(pe.Args, []string{Redacted}) {
+		t.Errorf("record error = %#v, want a redacted panic error", ts.records[1].Err)
+	}
+}
+
+func TestAuditRedact(t *testing.T) {
+	c := Command{
+		Args: []Arg{{Name: "user"}, {Name: "secrets", Sensitive: true, Variadic: true}},
+		Flags: []Flag{
+			{Name: "token", Short: "t", Sensitive: true},
+			{Name: "host", Short: "h"},
+			{Name: "verbose", Short: "v", Type: TypeBool, Sensitive: true},
+		},
+	}
+
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{"joe"}, []string{"joe"}},
+		{[]string{"joe", "a", "b"}, []string{"joe", Redacted, Redacted}},
+		{[]string{"--token", "abc", "joe"}, []string{"--token", Redacted, "joe"}},
+		{[]string{"-t=abc", "joe"}, []string{"-t=" + Redacted, "joe"}},
+		{[]string{"-h", "abc", "joe", "x"}, []string{"-h", "abc", "joe", Redacted}},
+		{[]string{"-v", "joe", "x"}, []string{"-v", "joe", Redacted}},
+		{[]string{"joe", "--", "--token"}, []string{"joe", "--", Redacted}},
+	} {
+		if got := c.redact(test.args); !slices.Equal(got, test.want) {
+			t.Errorf("redact(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := NewAuditWriter(&buf)
+	start := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	aw.Audit(AuditRecord{
+		Principal: Principal{Name: "joe", Roles: []string{"operator"}},
+		Command:   "lamps on",
+		Args:      []string{"kitchen"},
+		Start:     start,
+		Duration:  1500 * time.Millisecond,
+		Err:       errors.New("no lamps"),
+	})
+	aw.Audit(AuditRecord{Command: "uptime", Start: start})
+
+	want := `{"time":"2020-05-01T12:30:00Z","principal":"joe","roles":["operator"],"command":"lamps on","args":["kitchen"],"duration":"1.5s","error":"no lamps"}
+{"time":"2020-05-01T12:30:00Z","command":"uptime","args":[],"duration":"0s"}
+`
+	if buf.String() != want {
+		t.Errorf("AuditWriter wrote:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("invalid JSON line %q", line)
+		}
+	}
+	if err := aw.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+}
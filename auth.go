@@ -0,0 +1,176 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ebarkie/textcmd/internal/trie"
+)
+
+// Principal is the user on whose behalf commands are executed.
+type Principal struct {
+	Name  string   // Name identifies the user
+	Roles []string // Roles are the roles granted to the user
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal executing
+// commands.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Authorizer decides whether a principal may run a command.  A context
+// without a principal is authorized as the zero Principal.
+type Authorizer interface {
+	Authorize(p Principal, name string, c Command) bool
+}
+
+// Roles is an Authorizer which maps each role to the permissions it grants.
+// A principal may run a command if its roles grant all of the command's
+// Permissions.  The permission "*" grants every permission.
+type Roles map[string][]string
+
+// Authorize reports whether the principal's roles grant all of the
+// command's permissions.
+func (r Roles) Authorize(p Principal, _ string, c Command) bool {
+	for _, perm := range c.Permissions {
+		if !r.grants(p, perm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// grants reports whether any of the principal's roles grants perm.
+func (r Roles) grants(p Principal, perm string) bool {
+	for _, role := range p.Roles {
+		if slices.Contains(r[role], perm) || slices.Contains(r[role], "*") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PermissionError is returned by Exec when the principal is not authorized
+// to run the command.  It matches ErrPermission with errors.Is.
+type PermissionError struct {
+	Command   string    // Command is the command execution string
+	Principal Principal // Principal is the principal that was denied
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%v %q", ErrPermission, e.Command)
+}
+
+func (e *PermissionError) Is(target error) bool { return target == ErrPermission }
+
+// SetAuthorizer sets the Authorizer consulted before executing commands.
+// Commands the principal in the context is not authorized to run are also
+// hidden from completion, help and suggestions.  A nil Authorizer, the
+// default, authorizes everything.
+//
+// Which commands are hidden is cached per principal, by name and roles,
+// until the commands or Authorizer change, so its decisions should depend
+// only on them.
+func (sh *Shell) SetAuthorizer(a Authorizer) {
+	sh.auth.Store(&a)
+}
+
+// authorizer returns the Authorizer or nil if there is none.
+func (sh *Shell) authorizer() Authorizer {
+	if a := sh.auth.Load(); a != nil {
+		return *a
+	}
+
+	return nil
+}
+
+// authorize returns a *PermissionError if the principal in ctx is not
+// authorized to run the command.
+func (sh *Shell) authorize(ctx context.Context, name string, c Command) error {
+	a := sh.authorizer()
+	if a == nil {
+		return nil
+	}
+
+	p, _ := PrincipalFrom(ctx)
+	if !a.Authorize(p, name, c) {
+		return &PermissionError{Command: name, Principal: p}
+	}
+
+	return nil
+}
+
+// maxViews is the most principals whose views are cached.
+const maxViews = 64
+
+// views caches the views of principals for one command trie and Authorizer.
+type views struct {
+	root *trie.Node
+	auth *Authorizer
+	n    atomic.Int32
+	m    sync.Map // m maps a principal's key to its view
+}
+
+// viewKey returns the key of a principal's view.
+func viewKey(p Principal) string {
+	return p.Name + "\x00" + strings.Join(p.Roles, "\x00")
+}
+
+// view returns a shell of only the commands the principal in ctx is
+// authorized to run, for listing and completing them.  Views are cached
+// until the commands or Authorizer change so Authorize is called once per
+// command for each principal rather than on every call.
+func (sh *Shell) view(ctx context.Context) *Shell {
+	a := sh.auth.Load()
+	if a == nil || *a == nil {
+		return sh
+	}
+
+	root := sh.root.Load()
+	vs := sh.views.Load()
+	if vs == nil || vs.root != root || vs.auth != a {
+		vs = &views{root: root, auth: a}
+		sh.views.Store(vs)
+	}
+
+	p, _ := PrincipalFrom(ctx)
+	key := viewKey(p)
+	if v, ok := vs.m.Load(key); ok {
+		return v.(*Shell)
+	}
+
+	cmds, filtered := sh.cmds(), &trie.Node{}
+	for name := range cmds.Match("") {
+		c := cmds.Get(name).Val.(*Command)
+		if (*a).Authorize(p, name, *c) {
+			filtered.Add(name, c)
+		}
+	}
+
+	v := &Shell{}
+	v.root.Store(filtered)
+	if vs.n.Add(1) <= maxViews {
+		vs.m.Store(key, v)
+	}
+
+	return v
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func authShell() *Shell {
+	sh := testShell()
+	noop := func(context.Context, io.ReadWriter, ...string) error { return nil }
+	sh.RegisterCommand(Command{Func: noop, Summary: "Turn lamps off", Permissions: []string{"lamps"}}, "lamps off")
+	sh.RegisterCommand(Command{Func: noop, Summary: "Turn lamps on", Permissions: []string{"lamps"}}, "lamps on")
+	sh.RegisterCommand(Command{Func: noop, Summary: "Archive data", Permissions: []string{"archive", "lamps"}}, "archive")
+	sh.RegisterCommand(sh.HelpCommand(), "help")
+	sh.SetAuthorizer(Roles{
+		"operator": {"lamps", "archive"},
+		"lighting": {"lamps"},
+		"admin":    {"*"},
+	})
+
+	return sh
+}
+
+func TestAuthorize(t *testing.T) {
+	sh := authShell()
+
+	for _, test := range []struct {
+		roles []string
+		input string
+		err   error
+	}{
+		{nil, "uptime", nil},
+		{nil, "lamps on", ErrPermission},
+		{[]string{"viewer"}, "la of", ErrPermission},
+		{[]string{"lighting"}, "la of", nil},
+		{[]string{"lighting"}, "archive", ErrPermission},
+		{[]string{"viewer", "operator"}, "archive", nil},
+		{[]string{"admin"}, "archive", nil},
+	} {
+		t.Run(strings.Join(test.roles, ",")+" "+test.input, func(t *testing.T) {
+			ctx := WithPrincipal(context.Background(), Principal{Name: "joe", Roles: test.roles})
+			err := sh.Exec(ctx, nil, test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Exec error = %v, want %v", err, test.err)
+			}
+
+			var pe *PermissionError
+			if errors.As(err, &pe) && pe.Principal.Name != "joe" {
+				t.Errorf("PermissionError = %+v", pe)
+			}
+		})
+	}
+
+	// Without an Authorizer everything is allowed.
+	sh.SetAuthorizer(nil)
+	if err := sh.Exec(context.Background(), nil, "archive"); err != nil {
+		t.Errorf("Exec error = %v, want <nil>", err)
+	}
+}
+
+func TestAuthorizeHidden(t *testing.T) {
+	sh := authShell()
+	viewer := WithPrincipal(context.Background(), Principal{Roles: []string{"viewer"}})
+	operator := WithPrincipal(context.Background(), Principal{Roles: []string{"operator"}})
+
+	// Completion.
+	if completion, _ := sh.CompleteContext(viewer, "la"); completion != "la" {
+		t.Errorf("viewer completion = %q, want %q", completion, "la")
+	}
+	if completion, _ := sh.CompleteContext(operator, "la"); completion != "lamps o" {
+		t.Errorf("operator completion = %q, want %q", completion, "lamps o")
+	}
+	if completion, _ := sh.Complete("a"); completion != "a" {
+		t.Errorf("completion = %q, want %q", completion, "a")
+	}
+	var values []string
+	for _, c := range sh.CompleteAtContext(viewer, "l", 1).Candidates {
+		values = append(values, c.Value)
+	}
+	if want := []string{"logout", "loop"}; !slices.Equal(values, want) {
+		t.Errorf("viewer candidates %q, want %q", values, want)
+	}
+
+	// Suggestions.
+	var nf *CmdNotFoundError
+	if err := sh.Exec(viewer, nil, "archve"); !errors.As(err, &nf) || len(nf.Suggestions) > 0 {
+		t.Errorf("viewer Exec error = %v, want no suggestions", err)
+	}
+	if err := sh.Exec(operator, nil, "archve"); !errors.As(err, &nf) || !slices.Equal(nf.Suggestions, []string{"archive"}) {
+		t.Errorf("operator Exec error = %v, want suggestion", err)
+	}
+
+	// Help.
+	var buf bytes.Buffer
+	if err := sh.Exec(viewer, &buf, "help"); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	if strings.Contains(buf.String(), "lamps") || strings.Contains(buf.String(), "archive") {
+		t.Errorf("viewer help lists hidden commands:\n%s", buf.String())
+	}
+	if err := sh.Exec(viewer, &buf, "help lamps on"); !errors.Is(err, ErrCmdNotFound) {
+		t.Errorf("viewer help error = %v, want %v", err, ErrCmdNotFound)
+	}
+	buf.Reset()
+	if err := sh.Exec(operator, &buf, "help lamps"); err != nil || !strings.Contains(buf.String(), "Turn lamps off") {
+		t.Errorf("operator help = %q, %v", buf.String(), err)
+	}
+}
+
+func TestAuthorizeAbbrev(t *testing.T) {
+	var sh Shell
+	var ran string
+	for _, cmd := range []string{"archive", "arrow"} {
+		sh.RegisterCommand(Command{
+			Func: func(context.Context, io.ReadWriter, ...string) error {
+				ran = cmd
+				return nil
+			},
+			Permissions: map[string][]string{"archive": {"op"}}[cmd],
+		}, cmd)
+	}
+	sh.SetAuthorizer(Roles{"operator": {"op"}})
+	viewer := WithPrincipal(context.Background(), Principal{Roles: []string{"viewer"}})
+	operator := WithPrincipal(context.Background(), Principal{Roles: []string{"operator"}})
+
+	// A hidden command does not make an abbreviation ambiguous.
+	if completion, _ := sh.CompleteContext(viewer, "ar"); completion != "arrow" {
+		t.Errorf("viewer completion = %q, want %q", completion, "arrow")
+	}
+	if err := sh.Exec(viewer, nil, "ar"); err != nil || ran != "arrow" {
+		t.Errorf("viewer Exec ran %q, error %v, want %q", ran, err, "arrow")
+	}
+
+	// But it still does for a principal who may run it.
+	var ae *AmbiguousCmdError
+	if err := sh.Exec(operator, nil, "ar"); !errors.As(err, &ae) || !slices.Equal(ae.Candidates, []string{"archive", "arrow"}) {
+		t.Errorf("operator Exec error = %v, want ambiguous", err)
+	}
+
+	// Naming the hidden command is denied and errors don't reveal it.
+	if err := sh.Exec(viewer, nil, "arc"); !errors.Is(err, ErrPermission) {
+		t.Errorf("viewer Exec error = %v, want %v", err, ErrPermission)
+	}
+	var nf *CmdNotFoundError
+	if err := sh.Exec(viewer, nil, "arcive"); !errors.As(err, &nf) || slices.Contains(nf.Suggestions, "archive") {
+		t.Errorf("viewer Exec error = %v, want not found without %q", err, "archive")
+	}
+}
+
+// countAuthorizer counts the calls to an Authorizer.
+type countAuthorizer struct {
+	Authorizer
+	n *atomic.Int32
+}
+
+func (ca countAuthorizer) Authorize(p Principal, name string, c Command) bool {
+	ca.n.Add(1)
+	return ca.Authorizer.Authorize(p, name, c)
+}
+
+func TestAuthorizeViewCache(t *testing.T) {
+	sh := authShell()
+	var n atomic.Int32
+	sh.SetAuthorizer(countAuthorizer{Authorizer: Roles{"lighting": {"lamps"}}, n: &n})
+	ctx := WithPrincipal(context.Background(), Principal{Roles: []string{"lighting"}})
+
+	sh.CompleteContext(ctx, "la")
+	built := n.Load()
+	for range 3 {
+		sh.CompleteContext(ctx, "la")
+		sh.Exec(ctx, nil, "upt")
+	}
+	if calls := n.Load() - built; calls != 3 {
+		t.Errorf("Authorize called %d times after the view was built, want 3", calls)
+	}
+
+	// Registering a command invalidates the views.
+	sh.RegisterCommand(Command{Permissions: []string{"lamps"}}, "lamps dim")
+	if _, matches := sh.CompleteContext(ctx, "lamps d"); !slices.Equal(slices.Collect(matches), []string{"lamps dim"}) {
+		t.Errorf("completion does not include a new command")
+	}
+}
+
+func TestSessionAuthorize(t *testing.T) {
+	sh := authShell()
+	term := &testTerm{in: strings.NewReader("la\t of\r")}
+	s := NewSession(sh, term)
+	ctx := WithPrincipal(context.Background(), Principal{Roles: []string{"viewer"}})
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	if !strings.Contains(term.String(), "\a") {
+		t.Errorf("output %q did not ring the bell for a hidden command", term.String())
+	}
+	if !strings.Contains(term.String(), `permission denied "lamps off"`) {
+		t.Errorf("output %q does not report permission denied", term.String())
+	}
+}
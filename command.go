@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"iter"
+	"time"
+)
+
+// Command is a command function along with the documentation describing
+// how to use it.
+type Command struct {
+	Func CmdFunc // Func is called to run the command
+
+	Summary     string   // Summary is a short, one line description
+	Description string   // Description is a longer description
+	Usage       string   // Usage is the usage line, e.g. "archive [date]"
+	Args        []Arg    // Args describes the positional arguments
+	Flags       []Flag   // Flags describes the flags
+	Examples    []string // Examples are example command lines
+
+	// Complete optionally returns candidate argument values for
+	// completion.
+	Complete CompleteFunc
+
+	// Permissions are the permissions required to run the command, as
+	// checked by the shell's Authorizer.
+	Permissions []string
+
+	// Timeout optionally limits how long the command may run.  The
+	// context passed to Func is canceled once it elapses.
+	Timeout time.Duration
+}
+
+// Arg describes a positional command argument.  If a command declares any
+// arguments or flags Exec validates and converts them before running it; see
+// ValuesFrom.
+type Arg struct {
+	Name        string   // Name is the argument name
+	Description string   // Description describes the argument
+	Type        ArgType  // Type is the value type
+	Default     string   // Default is the value used when the argument is omitted
+	Required    bool     // Required arguments must be given
+	Choices     []string // Choices optionally restricts the allowed values
+	Variadic    bool     // Variadic collects the remaining arguments; last only
+	Sensitive   bool     // Sensitive values are redacted from audit records
+}
+
+// RegisterCommand adds a command and its documentation to the text command
+// shell under each of the command execution strings.  It's safe to call while
+// other goroutines use the shell.  A command without a Func is listed by help
+// and completed but Exec returns ErrCmdNotImplemented for it.
+func (sh *Shell) RegisterCommand(c Command, cmd ...string) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	root := sh.cmds()
+	for _, name := range cmd {
+		root = root.With(name, &c)
+	}
+	sh.root.Store(root)
+}
+
+// Replace registers a command under the command execution string cmd,
+// returning the command it replaced, if any.
+func (sh *Shell) Replace(c Command, cmd string) (prev Command, ok bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	root := sh.cmds()
+	if n := root.Get(cmd); n != nil && n.Val != nil {
+		prev, ok = *n.Val.(*Command), true
+	}
+	sh.root.Store(root.With(cmd, &c))
+
+	return
+}
+
+// Unregister removes the command registered under the exact command execution
+// string cmd, returning it.  A command registered under other strings as well
+// remains registered under them.
+func (sh *Shell) Unregister(cmd string) (c Command, ok bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	root, val := sh.cmds().Without(cmd)
+	if val == nil {
+		return
+	}
+	sh.root.Store(root)
+
+	return *val.(*Command), true
+}
+
+// Lookup returns the command registered under the exact command execution
+// string cmd.
+func (sh *Shell) Lookup(cmd string) (c Command, ok bool) {
+	if n := sh.cmds().Get(cmd); n != nil && n.Val != nil {
+		return *n.Val.(*Command), true
+	}
+
+	return
+}
+
+// Commands returns all of the registered command execution strings and
+// their commands in alphabetical order.  A command registered under
+// multiple strings is returned once for each of them.
+func (sh *Shell) Commands() iter.Seq2[string, Command] {
+	return func(yield func(string, Command) bool) {
+		cmds := sh.cmds()
+		for name := range cmds.Match("") {
+			if !yield(name, *cmds.Get(name).Val.(*Command)) {
+				return
+			}
+		}
+	}
+}
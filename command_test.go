@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRegisterCommand(t *testing.T) {
+	sh := testShell()
+
+	ran := false
+	sh.RegisterCommand(Command{
+		Func: func(context.Context, io.ReadWriter, ...string) error {
+			ran = true
+			return nil
+		},
+		Summary:  "Add a note",
+		Usage:    "note add <text>",
+		Args:     []Arg{{Name: "text", Description: "Note text"}},
+		Examples: []string{`note add "pump 3 is noisy"`},
+	}, "note add", "na")
+
+	for _, name := range []string{"note add", "na"} {
+		c, ok := sh.Lookup(name)
+		if !ok {
+			t.Fatalf("Lookup(%q) not found", name)
+		}
+		if c.Summary != "Add a note" || c.Usage != "note add <text>" {
+			t.Errorf("Lookup(%q) = %+v", name, c)
+		}
+		if len(c.Args) != 1 || c.Args[0].Name != "text" {
+			t.Errorf("Lookup(%q) args = %+v", name, c.Args)
+		}
+	}
+
+	if _, ok := sh.Lookup("note"); ok {
+		t.Errorf("Lookup(%q) found a partial command", "note")
+	}
+
+	if err := sh.Exec(context.Background(), nil, "na hello"); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	if !ran {
+		t.Error("command function not run")
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	sh := testShell()
+	sh.RegisterCommand(Command{Summary: "Show trace log"}, "watch log trace")
+
+	c, ok := sh.Unregister("watch log trace")
+	if !ok || c.Summary != "Show trace log" {
+		t.Errorf("Unregister = %+v, %t", c, ok)
+	}
+	if _, ok := sh.Unregister("watch log trace"); ok {
+		t.Error("Unregister found a removed command")
+	}
+	if _, ok := sh.Unregister("watch"); ok {
+		t.Error("Unregister found a partial command")
+	}
+
+	// "wa log t" is no longer a command and "watch log" completes to the
+	// only remaining command.
+	if err := sh.Exec(context.Background(), nil, "wa log t"); !errors.Is(err, ErrCmdNotFound) {
+		t.Errorf("Exec error = %v, want %v", err, ErrCmdNotFound)
+	}
+	if completion, _ := sh.Complete("watch log"); completion != "watch log debug" {
+		t.Errorf("Complete = %q, want %q", completion, "watch log debug")
+	}
+
+	// Removing the last command under a word prunes it.
+	sh.Unregister("watch log debug")
+	if completion, _ := sh.Complete("watch lo"); completion != "watch loops" {
+		t.Errorf("Complete = %q, want %q", completion, "watch loops")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	sh := testShell()
+
+	if _, ok := sh.Replace(Command{Summary: "Add a note"}, "note add"); ok {
+		t.Error("Replace returned a previous command for a new one")
+	}
+	prev, ok := sh.Replace(Command{Summary: "Append a note"}, "note add")
+	if !ok || prev.Summary != "Add a note" {
+		t.Errorf("Replace = %+v, %t", prev, ok)
+	}
+	if c, _ := sh.Lookup("note add"); c.Summary != "Append a note" {
+		t.Errorf("Lookup = %+v", c)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	sh := testShell()
+	sh.RegisterCommand(Command{Summary: "Show help"}, "help")
+
+	var names []string
+	for name, c := range sh.Commands() {
+		names = append(names, name)
+		if name == "help" && c.Summary != "Show help" {
+			t.Errorf("help summary = %q, want %q", c.Summary, "Show help")
+		}
+	}
+
+	if len(names) != 22 || names[0] != "?" || names[len(names)-1] != "whoami" {
+		t.Errorf("names = %q", names)
+	}
+}
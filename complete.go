@@ -0,0 +1,190 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ebarkie/textcmd/internal/trie"
+)
+
+// CompleteFunc returns the candidate values for the argument being typed.
+// args are the complete arguments before it and word is the partial
+// argument, which may be empty.  Candidates not beginning with word are
+// ignored.
+type CompleteFunc func(args []string, word string) []string
+
+// completeArg completes the last word of s as an argument of the command
+// it follows, if that command has a completer.  start is the byte offset in
+// s where the word begins and ok is false if the word is not an argument or
+// the command has no completer.
+func (sh *Shell) completeArg(s string) (start int, candidates []string, ok bool) {
+	tokens, err := lex(s)
+	if len(tokens) == 0 {
+		return
+	}
+
+	// The word being completed is empty if the line ends with a separator.
+	full, word, start := tokens, "", len(s)
+	if last := tokens[len(tokens)-1]; err != nil || last.end == len(s) {
+		full, word, start = tokens[:len(tokens)-1], last.val, last.start
+	}
+
+	m := sh.resolve(words(full))
+	if len(m) != 1 || m[0].cmd.Complete == nil {
+		return
+	}
+
+	// A word directly following the command name may be a subcommand, in
+	// which case command completion takes precedence.
+	if m[0].n == len(full) {
+		if sub := sh.cmds().Get(m[0].name + " "); sub != nil {
+			for range sub.Words(word, ' ') {
+				return
+			}
+		}
+	}
+
+	for _, c := range m[0].cmd.Complete(words(full[m[0].n:]), word) {
+		if strings.HasPrefix(c, word) && !slices.Contains(candidates, c) {
+			candidates = append(candidates, c)
+		}
+	}
+	slices.Sort(candidates)
+
+	return start, candidates, true
+}
+
+// commonPrefix returns the longest prefix shared by all of a.
+func commonPrefix(a []string) string {
+	if len(a) == 0 {
+		return ""
+	}
+
+	p := a[0]
+	for _, s := range a[1:] {
+		for !strings.HasPrefix(s, p) {
+			_, size := utf8.DecodeLastRuneInString(p)
+			p = p[:len(p)-size]
+		}
+	}
+
+	return p
+}
+
+// Completion is the result of completing the word at a cursor position.
+type Completion struct {
+	Start, End int         // Start and End are the byte range of the line to replace
+	Candidates []Candidate // Candidates are the possible replacements
+	Space      bool        // Space is set if a space should follow the replacement
+}
+
+// Candidate is a possible replacement for the word being completed.
+type Candidate struct {
+	Value       string // Value is the replacement, quoted as needed
+	Description string // Description is an optional short description
+}
+
+// CompleteAt completes the word at byte offset pos of line, which is
+// typically the cursor position of a line editor.  Command words and, for
+// commands with completers, argument values are completed.  Only the part of
+// the word before pos is considered but the whole word is replaced.
+//
+// If there is a single candidate the line editor should replace the range
+// with it, appending a space if Space is set.  Otherwise it may replace the
+// range with the longest common prefix of the candidates and list them.
+func (sh *Shell) CompleteAt(line string, pos int) Completion {
+	return sh.CompleteAtContext(context.Background(), line, pos)
+}
+
+// CompleteAtContext is like CompleteAt but only completes the commands the
+// principal carried by ctx is authorized to run.
+func (sh *Shell) CompleteAtContext(ctx context.Context, line string, pos int) Completion {
+	return sh.view(ctx).completeAt(line, pos)
+}
+
+// completeAt implements CompleteAt.
+func (sh *Shell) completeAt(line string, pos int) (c Completion) {
+	pos = max(0, min(pos, len(line)))
+
+	c.End = pos
+	tokens, _ := lex(line)
+	for _, t := range tokens {
+		if t.start < pos && pos <= t.end {
+			c.End = t.end
+		}
+	}
+
+	c.Start, c.Candidates = sh.completeCmd(line[:pos])
+	if start, values, ok := sh.completeArg(line[:pos]); ok {
+		c.Start = start
+		for _, v := range values {
+			c.Candidates = append(c.Candidates, Candidate{Value: quote(v)})
+		}
+	}
+	// A separator already following the word is kept rather than doubled.
+	next, _ := utf8.DecodeRuneInString(line[c.End:])
+	c.Space = len(c.Candidates) == 1 && (c.End == len(line) || !unicode.IsSpace(next))
+
+	return
+}
+
+// completeCmd completes the last word of s as a command word.  start is the
+// byte offset in s where the word begins.
+func (sh *Shell) completeCmd(s string) (start int, candidates []Candidate) {
+	tokens, err := lex(s)
+	full, word, start := tokens, "", len(s)
+	if len(tokens) > 0 {
+		if last := tokens[len(tokens)-1]; err != nil || last.end == len(s) {
+			full, word, start = tokens[:len(tokens)-1], last.val, last.start
+		}
+	}
+	if word == "" && start < len(s) {
+		// An empty quoted word cannot be a command word.
+		return
+	}
+
+	// Find every command level the preceding words may abbreviate.
+	levels := []*trie.Node{sh.cmds()}
+	for _, t := range full {
+		var next []*trie.Node
+		for _, n := range levels {
+			for w, cur := range n.Words(t.val, ' ') {
+				if sep := cur.Get(" "); sep != nil {
+					next = append(next, sep)
+				}
+				if w == t.val {
+					break
+				}
+			}
+		}
+		levels = next
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range levels {
+		for w, cur := range n.Words(word, ' ') {
+			if seen[w] {
+				continue
+			}
+			seen[w] = true
+
+			var desc string
+			if cur.Val != nil {
+				desc = cur.Val.(*Command).Summary
+			}
+			candidates = append(candidates, Candidate{Value: quote(w), Description: desc})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b Candidate) int {
+		return strings.Compare(a.Value, b.Value)
+	})
+
+	return
+}
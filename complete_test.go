@@ -0,0 +1,236 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func completeShell() *Shell {
+	sh := testShell()
+
+	zones := func(args []string, word string) []string {
+		return []string{"kitchen", "kids room", "garage", "garden"}
+	}
+	sh.RegisterCommand(Command{Complete: zones}, "lamps on")
+	sh.RegisterCommand(Command{Complete: zones}, "lamps off")
+	sh.RegisterCommand(Command{
+		Complete: func(args []string, word string) []string {
+			if len(args) == 0 {
+				return []string{"2020-01-01", "2020-01-02", "2021-06-30"}
+			}
+			return []string{"csv", "json"}
+		},
+	}, "archive")
+	sh.RegisterCommand(Command{
+		Complete: func([]string, string) []string { return []string{"all"} },
+	}, "watch log")
+
+	return sh
+}
+
+func TestCompleteArgs(t *testing.T) {
+	sh := completeShell()
+
+	for _, test := range []struct {
+		name       string
+		input      string
+		completion string
+		matches    []string
+	}{
+		{
+			name:       "unique",
+			input:      "lamps on kit",
+			completion: "lamps on kitchen",
+			matches:    []string{"lamps on kitchen"},
+		},
+		{
+			name:       "common prefix",
+			input:      "la off g",
+			completion: "la off gar",
+			matches:    []string{"la off garage", "la off garden"},
+		},
+		{
+			name:       "escaped",
+			input:      "lamps on kid",
+			completion: `lamps on kids\ room`,
+			matches:    []string{`lamps on kids\ room`},
+		},
+		{
+			name:       "quoted partial",
+			input:      `lamps on "kids r`,
+			completion: `lamps on kids\ room`,
+			matches:    []string{`lamps on kids\ room`},
+		},
+		{
+			name:       "empty word",
+			input:      "archive ",
+			completion: "archive 202",
+			matches:    []string{"archive 2020-01-01", "archive 2020-01-02", "archive 2021-06-30"},
+		},
+		{
+			name:       "args so far",
+			input:      "archive 2020-01-01 j",
+			completion: "archive 2020-01-01 json",
+			matches:    []string{"archive 2020-01-01 json"},
+		},
+		{
+			name:       "no candidates",
+			input:      "lamps on x",
+			completion: "lamps on x",
+			matches:    []string{},
+		},
+		{
+			name:       "subcommand first",
+			input:      "watch log ",
+			completion: "watch log ",
+			matches:    []string{"watch log debug", "watch log trace"},
+		},
+		{
+			name:       "no completer",
+			input:      "date ",
+			completion: "date",
+			matches:    []string{"date"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			completion, matches := sh.Complete(test.input)
+			if completion != test.completion {
+				t.Errorf("completion = %q, want %q", completion, test.completion)
+			}
+
+			got := slices.Collect(matches)
+			if len(got) == 0 && len(test.matches) == 0 {
+				return
+			}
+			if !slices.Equal(got, test.matches) {
+				t.Errorf("matches = %q, want %q", got, test.matches)
+			}
+		})
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	for _, test := range []struct {
+		a []string
+		p string
+	}{
+		{nil, ""},
+		{[]string{"garage"}, "garage"},
+		{[]string{"garage", "garden"}, "gar"},
+		{[]string{"écho", "éclair"}, "éc"},
+		{[]string{"ça", "ço"}, "ç"},
+		{[]string{"a", "b"}, ""},
+	} {
+		if p := commonPrefix(test.a); p != test.p {
+			t.Errorf("commonPrefix(%q) = %q, want %q", test.a, p, test.p)
+		}
+	}
+}
+
+func TestCompleteAt(t *testing.T) {
+	sh := completeShell()
+	sh.RegisterCommand(Command{Summary: "Show the date"}, "date")
+
+	for _, test := range []struct {
+		name       string
+		line       string
+		pos        int
+		start, end int
+		candidates []Candidate
+		space      bool
+	}{
+		{
+			name:       "start of line",
+			line:       "wa",
+			pos:        0,
+			start:      0,
+			end:        0,
+			candidates: []Candidate{{Value: "?"}, {Value: "archive"}, {Value: "conditions"}, {Value: "date", Description: "Show the date"}, {Value: "exit"}, {Value: "health"}, {Value: "help"}, {Value: "lamps"}, {Value: "logout"}, {Value: "loop"}, {Value: "quit"}, {Value: "time"}, {Value: "trend"}, {Value: "uname"}, {Value: "uptime"}, {Value: "version"}, {Value: "watch"}, {Value: "whoami"}},
+		},
+		{
+			name:       "unique with description",
+			line:       "da",
+			pos:        2,
+			start:      0,
+			end:        2,
+			candidates: []Candidate{{Value: "date", Description: "Show the date"}},
+			space:      true,
+		},
+		{
+			name:       "middle of line",
+			line:       "wa lo d extra",
+			pos:        5,
+			start:      3,
+			end:        5,
+			candidates: []Candidate{{Value: "log"}, {Value: "loops"}},
+		},
+		{
+			name:       "middle of word",
+			line:       "watch log tr",
+			pos:        11,
+			start:      10,
+			end:        12,
+			candidates: []Candidate{{Value: "trace"}},
+			space:      true,
+		},
+		{
+			name:       "before separator",
+			line:       "wa log",
+			pos:        2,
+			start:      0,
+			end:        2,
+			candidates: []Candidate{{Value: "watch"}},
+		},
+		{
+			name:       "after separator",
+			line:       "watch log  ",
+			pos:        10,
+			start:      10,
+			end:        10,
+			candidates: []Candidate{{Value: "debug"}, {Value: "trace"}},
+		},
+		{
+			name:       "argument",
+			line:       "la on kids",
+			pos:        10,
+			start:      6,
+			end:        10,
+			candidates: []Candidate{{Value: `kids\ room`}},
+			space:      true,
+		},
+		{
+			name:       "no match",
+			line:       "xyz",
+			pos:        3,
+			start:      0,
+			end:        3,
+			candidates: nil,
+		},
+		{
+			name:       "pos past end",
+			line:       "upt",
+			pos:        99,
+			start:      0,
+			end:        3,
+			candidates: []Candidate{{Value: "uptime"}},
+			space:      true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := sh.CompleteAt(test.line, test.pos)
+			if c.Start != test.start || c.End != test.end {
+				t.Errorf("range = [%d, %d), want [%d, %d)", c.Start, c.End, test.start, test.end)
+			}
+			if !slices.Equal(c.Candidates, test.candidates) {
+				t.Errorf("candidates = %q, want %q", c.Candidates, test.candidates)
+			}
+			if c.Space != test.space {
+				t.Errorf("space = %t, want %t", c.Space, test.space)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxSuggestDist is the largest edit distance considered when
+	// suggesting commands.
+	maxSuggestDist = 2
+
+	// maxSuggestions is the most suggestions a CmdNotFoundError
+	// carries.
+	maxSuggestions = 3
+)
+
+// CmdNotFoundError is returned by Exec when the command line does not match
+// any registered command.  It matches ErrCmdNotFound with errors.Is.
+type CmdNotFoundError struct {
+	Input       string   // Input is the command line
+	Prefix      string   // Prefix is the longest registered command prefix matched
+	Suggestions []string // Suggestions are similar commands, closest first
+}
+
+func (e *CmdNotFoundError) Error() string {
+	s := ErrCmdNotFound.Error()
+	if e.Input != "" {
+		s += fmt.Sprintf(" %q", e.Input)
+	}
+	if len(e.Suggestions) > 0 {
+		s += ", did you mean " + quoteList(e.Suggestions, "or") + "?"
+	}
+
+	return s
+}
+
+func (e *CmdNotFoundError) Is(target error) bool { return target == ErrCmdNotFound }
+
+// AmbiguousCmdError is returned by Exec when an abbreviated command line
+// matches more than one registered command.  It matches ErrAmbiguousCmd with
+// errors.Is.
+type AmbiguousCmdError struct {
+	Input      string   // Input is the command line
+	Prefix     string   // Prefix is the longest registered command prefix matched
+	Candidates []string // Candidates are the matching commands
+}
+
+func (e *AmbiguousCmdError) Error() string {
+	return fmt.Sprintf("%v %q, could be %s", ErrAmbiguousCmd, e.Input, quoteList(e.Candidates, "or"))
+}
+
+func (e *AmbiguousCmdError) Is(target error) bool { return target == ErrAmbiguousCmd }
+
+// PanicError is returned by Exec when a command panics.  It matches
+// ErrCmdPanic with errors.Is and unwraps to the panic value if it's an error.
+type PanicError struct {
+	Command string   // Command is the command execution string
+	Args    []string // Args are the arguments as entered
+	Value   any      // Value is the value passed to panic
+	Stack   []byte   // Stack is the stack trace of the panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v %q: %v", ErrCmdPanic, e.Command, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool { return target == ErrCmdPanic }
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutError is returned by Exec when a command fails after its Timeout
+// elapsed.  It matches ErrCmdTimeout and context.DeadlineExceeded with
+// errors.Is.
+type TimeoutError struct {
+	Command string        // Command is the command execution string
+	Timeout time.Duration // Timeout is the command's Timeout
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%v %q after %v", ErrCmdTimeout, e.Command, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool { return target == ErrCmdTimeout }
+
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// quoteList returns a human readable list of quoted strings joined with
+// conj, e.g. `"a", "b" or "c"`.
+func quoteList(a []string, conj string) string {
+	q := make([]string, len(a))
+	for i := range a {
+		q[i] = fmt.Sprintf("%q", a[i])
+	}
+	if len(q) < 2 {
+		return strings.Join(q, "")
+	}
+
+	return strings.Join(q[:len(q)-1], ", ") + " " + conj + " " + q[len(q)-1]
+}
+
+// suggest returns the registered commands closest to the command line words,
+// closest first.  Short inputs tolerate fewer edits so a single mistyped
+// letter does not suggest every short command.
+func (sh *Shell) suggest(words []string) []string {
+	key := strings.Join(words, " ")
+	max := min(maxSuggestDist, utf8.RuneCountInString(key)/2)
+
+	type near struct {
+		cmd  string
+		dist int
+	}
+	var nears []near
+	for cmd, dist := range sh.cmds().Near(key, ' ', max) {
+		nears = append(nears, near{cmd, dist})
+	}
+	slices.SortStableFunc(nears, func(a, b near) int {
+		return cmp.Compare(a.dist, b.dist)
+	})
+
+	var s []string
+	for i := 0; i < len(nears) && i < maxSuggestions; i++ {
+		s = append(s, nears[i].cmd)
+	}
+
+	return s
+}
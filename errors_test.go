@@ -0,0 +1,154 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCmdNotFoundError(t *testing.T) {
+	sh := testShell()
+
+	for _, test := range []struct {
+		input       string
+		prefix      string
+		suggestions []string
+		msg         string
+	}{
+		{"tiem", "", []string{"time"}, `command not found "tiem", did you mean "time"?`},
+		{"watch lgo debug", "watch ", []string{"watch log debug"}, `command not found "watch lgo debug", did you mean "watch log debug"?`},
+		{"lamps onn", "lamps ", []string{"lamps on", "lamps off"}, `command not found "lamps onn", did you mean "lamps on" or "lamps off"?`},
+		{"x", "", nil, `command not found "x"`},
+		{"", "", nil, "command not found"},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			err := sh.Exec(context.Background(), nil, test.input)
+			if !errors.Is(err, ErrCmdNotFound) {
+				t.Fatalf("err = %v, want %v", err, ErrCmdNotFound)
+			}
+
+			var nferr *CmdNotFoundError
+			if !errors.As(err, &nferr) {
+				t.Fatalf("err = %T, want *CmdNotFoundError", err)
+			}
+			if nferr.Prefix != test.prefix {
+				t.Errorf("prefix = %q, want %q", nferr.Prefix, test.prefix)
+			}
+			if !slices.Equal(nferr.Suggestions, test.suggestions) {
+				t.Errorf("suggestions = %q, want %q", nferr.Suggestions, test.suggestions)
+			}
+			if err.Error() != test.msg {
+				t.Errorf("message = %q, want %q", err.Error(), test.msg)
+			}
+		})
+	}
+}
+
+func TestAmbiguousCmdError(t *testing.T) {
+	sh := testShell()
+
+	err := sh.Exec(context.Background(), nil, "la o kitchen")
+	if !errors.Is(err, ErrAmbiguousCmd) {
+		t.Fatalf("err = %v, want %v", err, ErrAmbiguousCmd)
+	}
+
+	var aerr *AmbiguousCmdError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("err = %T, want *AmbiguousCmdError", err)
+	}
+	if aerr.Prefix != "lamps o" {
+		t.Errorf("prefix = %q, want %q", aerr.Prefix, "lamps o")
+	}
+	if want := []string{"lamps off", "lamps on"}; !slices.Equal(aerr.Candidates, want) {
+		t.Errorf("candidates = %q, want %q", aerr.Candidates, want)
+	}
+	if want := `ambiguous command "la o kitchen", could be "lamps off" or "lamps on"`; err.Error() != want {
+		t.Errorf("message = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	sh := testShell()
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error {
+		var c *Command
+		_ = c.Summary
+		return nil
+	}, "trend")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error {
+		panic("no data")
+	}, "archive")
+
+	err := sh.Exec(context.Background(), nil, "tr 24h")
+	if !errors.Is(err, ErrCmdPanic) {
+		t.Fatalf("Exec error = %v, want %v", err, ErrCmdPanic)
+	}
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Exec error is %T, want %T", err, pe)
+	}
+	if pe.Command != "trend" || !slices.Equal(pe.Args, []string{"24h"}) {
+		t.Errorf("PanicError command %q args %q, want %q %q", pe.Command, pe.Args, "trend", []string{"24h"})
+	}
+	var re runtime.Error
+	if !errors.As(err, &re) {
+		t.Errorf("PanicError does not unwrap to a runtime.Error")
+	}
+	if !strings.Contains(string(pe.Stack), "TestPanicError") {
+		t.Errorf("PanicError stack does not include the command:\n%s", pe.Stack)
+	}
+
+	err = sh.Exec(context.Background(), nil, "archive")
+	if want := `command panicked "archive": no data`; err == nil || err.Error() != want {
+		t.Errorf("Exec error = %v, want %s", err, want)
+	}
+	if errors.Unwrap(err) != nil {
+		t.Errorf("PanicError unwraps to %v, want <nil>", errors.Unwrap(err))
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	sh := testShell()
+	sh.RegisterCommand(Command{
+		Func: func(ctx context.Context, _ io.ReadWriter, _ ...string) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: 10 * time.Millisecond,
+	}, "trend")
+	sh.RegisterCommand(Command{
+		Func:    func(context.Context, io.ReadWriter, ...string) error { return nil },
+		Timeout: time.Minute,
+	}, "archive")
+
+	err := sh.Exec(context.Background(), nil, "tr")
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Command != "trend" || te.Timeout != 10*time.Millisecond {
+		t.Fatalf("Exec error = %v, want a TimeoutError", err)
+	}
+	if !errors.Is(err, ErrCmdTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Exec error %v does not match %v and %v", err, ErrCmdTimeout, context.DeadlineExceeded)
+	}
+	if want := `command timed out "trend" after 10ms`; err.Error() != want {
+		t.Errorf("Exec error = %q, want %q", err.Error(), want)
+	}
+
+	if err := sh.Exec(context.Background(), nil, "archive"); err != nil {
+		t.Errorf("Exec error = %v, want <nil>", err)
+	}
+
+	// Canceling the parent context is not a timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sh.Exec(ctx, nil, "tr"); err != context.Canceled {
+		t.Errorf("Exec error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// History expansion errors.
+var (
+	ErrEventNotFound   = errors.New("event not found")
+	ErrBadSubstitution = errors.New("substitution failed")
+)
+
+// Expand performs bash style history expansion on s, replacing
+// these designators with entries from the history:
+//
+//	!!       the previous command
+//	!n       command number n
+//	!-n      the command n commands back
+//	!prefix  the most recent command beginning with prefix
+//	!?str?   the most recent command containing str
+//	^old^new the previous command with old replaced by new
+//
+// A ! followed by whitespace, =, (, " or the end of the line is
+// not a designator and neither is one preceded by a backslash
+// or within single quotes.  As in bash a backslash is literal
+// within single quotes and a single quote is literal within
+// double quotes.  An error wrapping ErrEventNotFound or
+// ErrBadSubstitution is returned if an expansion fails.
+func (h *History) Expand(s string) (string, error) {
+	if strings.HasPrefix(s, "^") {
+		return h.substitute(s[1:])
+	}
+
+	var buf strings.Builder
+	single, double := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'' && !double:
+			single = !single
+		case c == '"' && !single:
+			double = !double
+		case c == '\\' && !single && i+1 < len(s):
+			buf.WriteByte(c)
+			i++
+			c = s[i]
+		case c == '!' && !single && i+1 < len(s) && !strings.ContainsRune(" \t=(\"", rune(s[i+1])):
+			e, n, err := h.event(s[i+1:])
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(e)
+			i += n
+			continue
+		}
+		buf.WriteByte(c)
+	}
+
+	return buf.String(), nil
+}
+
+// event returns the entry referred to by the designator at the
+// start of s, which follows a !, and its length.
+func (h *History) event(s string) (string, int, error) {
+	var d string
+	i := -1
+	switch {
+	case s[0] == '!':
+		d, i = "!", len(h.entries)-1
+	case s[0] == '?':
+		str, _, _ := strings.Cut(s[1:], "?")
+		d = s[:min(len(s), len(str)+2)]
+		_, i = h.SearchBack(str, len(h.entries))
+	case s[0] == '-' || s[0] >= '0' && s[0] <= '9':
+		d = s[:1+len(s[1:])-len(strings.TrimLeft(s[1:], "0123456789"))]
+		n, err := strconv.Atoi(d)
+		switch {
+		case err != nil:
+		case n < 0:
+			i = len(h.entries) + n
+		default:
+			i = slices.IndexFunc(h.entries, func(e HistoryEntry) bool {
+				return e.Num == n
+			})
+		}
+	default:
+		d = s
+		if j := strings.IndexAny(s, " \t"); j >= 0 {
+			d = s[:j]
+		}
+		for j := len(h.entries) - 1; j >= 0; j-- {
+			if strings.HasPrefix(h.entries[j].Line, d) {
+				i = j
+				break
+			}
+		}
+	}
+
+	e, ok := h.Entry(i)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: !%s", ErrEventNotFound, d)
+	}
+
+	return e.Line, len(d), nil
+}
+
+// substitute performs a ^old^new^ quick substitution on the
+// previous command.  s follows the leading ^.
+func (h *History) substitute(s string) (string, error) {
+	old, rest, _ := strings.Cut(s, "^")
+	repl, tail, _ := strings.Cut(rest, "^")
+
+	e, ok := h.Entry(len(h.entries) - 1)
+	if !ok {
+		return "", fmt.Errorf("%w: ^%s", ErrEventNotFound, old)
+	}
+	if old == "" || !strings.Contains(e.Line, old) {
+		return "", fmt.Errorf("%w: ^%s", ErrBadSubstitution, old)
+	}
+
+	return strings.Replace(e.Line, old, repl, 1) + tail, nil
+}
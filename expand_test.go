@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	h := NewHistory(4)
+	for _, s := range []string{"uptime", "watch log debug", "date", "lamps on kitchen", "watch loops"} {
+		h.Add(s)
+	}
+
+	for _, test := range []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"date", "date", nil},
+		{"!!", "watch loops", nil},
+		{"!! now", "watch loops now", nil},
+		{"sudo !!", "sudo watch loops", nil},
+		{"!2", "watch log debug", nil},
+		{"!4 x", "lamps on kitchen x", nil},
+		{"!-1", "watch loops", nil},
+		{"!-3", "date", nil},
+		{"!wa", "watch loops", nil},
+		{"!watch log", "watch loops log", nil},
+		{"!la", "lamps on kitchen", nil},
+		{"!?debug?", "watch log debug", nil},
+		{"!?kit", "lamps on kitchen", nil},
+		{"^debug^trace", "watch loops", ErrBadSubstitution},
+		{"^loops^conditions", "watch conditions", nil},
+		{"^loops^log^ trace", "watch log trace", nil},
+		{"echo ! !=x !(", "echo ! !=x !(", nil},
+		{"trailing!", "trailing!", nil},
+		{`echo \!!`, `echo \!!`, nil},
+		{"echo '!!'", "echo '!!'", nil},
+		{`echo "!!"`, `echo "watch loops"`, nil},
+		{`echo "it's !!"`, `echo "it's watch loops"`, nil},
+		{`echo '\' !!`, `echo '\' watch loops`, nil},
+		{`note add "pump 3 is noisy!"`, `note add "pump 3 is noisy!"`, nil},
+		{"!1", "", ErrEventNotFound},
+		{"!99", "", ErrEventNotFound},
+		{"!-9", "", ErrEventNotFound},
+		{"!xyz", "", ErrEventNotFound},
+		{"!?xyz?", "", ErrEventNotFound},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := h.Expand(test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("Expand(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+
+	if _, err := NewHistory(0).Expand("!!"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("empty history err = %v, want %v", err, ErrEventNotFound)
+	}
+	if _, err := NewHistory(0).Expand("^a^b"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("empty history err = %v, want %v", err, ErrEventNotFound)
+	}
+}
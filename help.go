@@ -0,0 +1,201 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultWidth is the terminal width assumed when the context does not
+// carry one.
+const DefaultWidth = 80
+
+type widthKey struct{}
+
+// WithWidth returns a copy of ctx carrying the terminal width in columns.
+// Commands such as help use it to format their output.
+func WithWidth(ctx context.Context, cols int) context.Context {
+	return context.WithValue(ctx, widthKey{}, cols)
+}
+
+// Width returns the terminal width carried by ctx or DefaultWidth if there
+// is none.
+func Width(ctx context.Context) int {
+	if cols, ok := ctx.Value(widthKey{}).(int); ok && cols > 0 {
+		return cols
+	}
+
+	return DefaultWidth
+}
+
+// HelpCommand returns a command which prints help generated from the
+// documentation of the registered commands.  It is opt-in and is typically
+// registered as:
+//
+//	sh.RegisterCommand(sh.HelpCommand(), "help", "?")
+//
+// With no arguments all commands are listed, grouped by first word.  If the
+// arguments name a single command, each word possibly an unambiguous
+// abbreviation, detailed help for it is shown.  Otherwise the arguments
+// narrow the list to the commands beginning with them, so "help watch" lists
+// the watch commands and "help wa lo" those beginning with "watch lo".
+// Commands the principal is not authorized to run are not shown.
+func (sh *Shell) HelpCommand() Command {
+	return Command{
+		Func:        sh.help,
+		Summary:     "Show help for commands",
+		Description: "With no arguments all commands are listed.  Otherwise the commands beginning with the arguments are listed or, if they name a single command, detailed help for it is shown.",
+		Usage:       "help [command...]",
+		Args:        []Arg{{Name: "command", Description: "Command or command prefix, which may be abbreviated", Variadic: true}},
+		Examples:    []string{"help", "help watch", "help wa lo d"},
+	}
+}
+
+func (sh *Shell) help(ctx context.Context, rw io.ReadWriter, args ...string) error {
+	sh = sh.view(ctx)
+	width := Width(ctx)
+
+	if len(args) > 0 {
+		if m := sh.resolve(args); len(m) == 1 && m[0].n == len(args) {
+			helpDetail(rw, width, m[0].name, *m[0].cmd)
+			return nil
+		}
+	}
+
+	cmds := sh.cmds()
+	prefix, cur := cmds.Find(strings.Join(args, " "), ' ')
+	if cur == nil {
+		return &CmdNotFoundError{
+			Input:       strings.Join(args, " "),
+			Prefix:      prefix,
+			Suggestions: sh.suggest(args),
+		}
+	}
+
+	var names, summaries []string
+	for name := range cmds.Match(prefix) {
+		names = append(names, name)
+		summaries = append(summaries, cmds.Get(name).Val.(*Command).Summary)
+	}
+	helpList(rw, width, names, summaries)
+
+	return nil
+}
+
+// helpList writes a two column list of command names and summaries.  Groups
+// of commands sharing a first word are separated by blank lines.
+func helpList(w io.Writer, width int, names, summaries []string) {
+	col := 0
+	for _, name := range names {
+		col = max(col, len(name))
+	}
+	col = min(col+2, width/2)
+
+	prev := ""
+	for i, name := range names {
+		if i > 0 && firstWord(name) != firstWord(prev) &&
+			(strings.Contains(name, " ") || strings.Contains(prev, " ")) {
+			fmt.Fprintln(w)
+		}
+		prev = name
+
+		lines := wrap(summaries[i], width-col-2)
+		if len(name) >= col && len(lines) > 0 {
+			fmt.Fprintf(w, "  %s\n", name)
+			name = ""
+		}
+		if len(lines) == 0 {
+			fmt.Fprintf(w, "  %s\n", name)
+			continue
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %-*s%s\n", col, name, line)
+			name = ""
+		}
+	}
+}
+
+// helpDetail writes detailed help for a command.
+func helpDetail(w io.Writer, width int, name string, c Command) {
+	fmt.Fprintf(w, "Usage: %s\n", c.usage(name))
+
+	for _, text := range []string{c.Summary, c.Description} {
+		if text == "" {
+			continue
+		}
+		fmt.Fprintln(w)
+		for _, line := range wrap(text, width) {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(c.Args) > 0 {
+		fmt.Fprintln(w, "\nArguments:")
+		names := make([]string, len(c.Args))
+		descs := make([]string, len(c.Args))
+		for i, a := range c.Args {
+			names[i], descs[i] = a.Name, a.Description
+		}
+		helpList(w, width, names, descs)
+	}
+
+	if len(c.Flags) > 0 {
+		fmt.Fprintln(w, "\nFlags:")
+		names := make([]string, len(c.Flags))
+		descs := make([]string, len(c.Flags))
+		for i, f := range c.Flags {
+			names[i] = "--" + f.Name
+			if f.Short != "" {
+				names[i] = "-" + f.Short + ", " + names[i]
+			}
+			if f.Type != TypeBool {
+				names[i] += " " + f.Type.String()
+			}
+			descs[i] = f.Description
+			if f.Default != "" {
+				descs[i] += " (default " + f.Default + ")"
+			}
+		}
+		helpList(w, width, names, descs)
+	}
+
+	if len(c.Examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, ex := range c.Examples {
+			fmt.Fprintf(w, "  %s\n", ex)
+		}
+	}
+}
+
+// firstWord returns the first space separated word of s.
+func firstWord(s string) string {
+	w, _, _ := strings.Cut(s, " ")
+	return w
+}
+
+// wrap splits text into lines of at most width columns, breaking at spaces.
+// Words longer than width are left on their own line.
+func wrap(text string, width int) (lines []string) {
+	var line string
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return
+}
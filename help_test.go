@@ -0,0 +1,150 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func helpShell() *Shell {
+	var sh Shell
+	sh.RegisterCommand(sh.HelpCommand(), "help", "?")
+	sh.RegisterCommand(Command{Summary: "Show current conditions"}, "conditions")
+	sh.RegisterCommand(Command{Summary: "Show the date"}, "date")
+	sh.RegisterCommand(Command{Summary: "Turn the lamps off"}, "lamps off")
+	sh.RegisterCommand(Command{Summary: "Turn the lamps on"}, "lamps on")
+	sh.RegisterCommand(Command{Summary: "Watch current conditions as they change"}, "watch conditions")
+	sh.RegisterCommand(Command{
+		Summary:     "Watch debug log messages",
+		Description: "Log messages at debug level and above are written until the command is interrupted.",
+		Args:        []Arg{{Name: "filter", Description: "Only show messages containing filter"}},
+		Examples:    []string{"watch log debug", "watch log debug archive"},
+	}, "watch log debug")
+	sh.RegisterCommand(Command{Summary: "Watch trace log messages"}, "watch log trace")
+
+	return &sh
+}
+
+func TestHelp(t *testing.T) {
+	sh := helpShell()
+
+	for _, test := range []struct {
+		name  string
+		input string
+		width int
+		out   string
+	}{
+		{
+			name:  "all",
+			input: "help",
+			width: 80,
+			out: `  ?                 Show help for commands
+  conditions        Show current conditions
+  date              Show the date
+  help              Show help for commands
+
+  lamps off         Turn the lamps off
+  lamps on          Turn the lamps on
+
+  watch conditions  Watch current conditions as they change
+  watch log debug   Watch debug log messages
+  watch log trace   Watch trace log messages
+`,
+		},
+		{
+			name:  "narrow",
+			input: "help watch",
+			width: 40,
+			out: `  watch conditions  Watch current
+                    conditions as they
+                    change
+  watch log debug   Watch debug log
+                    messages
+  watch log trace   Watch trace log
+                    messages
+`,
+		},
+		{
+			name:  "prefix",
+			input: "? wa l",
+			width: 80,
+			out: `  watch log debug  Watch debug log messages
+  watch log trace  Watch trace log messages
+`,
+		},
+		{
+			name:  "detail",
+			input: "help wa lo d",
+			width: 50,
+			out: `Usage: watch log debug [filter]
+
+Watch debug log messages
+
+Log messages at debug level and above are written
+until the command is interrupted.
+
+Arguments:
+  filter  Only show messages containing filter
+
+Examples:
+  watch log debug
+  watch log debug archive
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := WithWidth(context.Background(), test.width)
+			if err := sh.Exec(ctx, &buf, test.input); err != nil {
+				t.Fatalf("Exec error: %v", err)
+			}
+			if buf.String() != test.out {
+				t.Errorf("output:\n%s\nwant:\n%s", buf.String(), test.out)
+			}
+		})
+	}
+}
+
+func TestHelpAmbiguous(t *testing.T) {
+	sh := helpShell()
+	sh.RegisterCommand(Command{Summary: "Watch loop packets"}, "watch loops")
+
+	want := `  watch log debug  Watch debug log messages
+  watch log trace  Watch trace log messages
+  watch loops      Watch loop packets
+`
+	for _, input := range []string{"help wa lo", "help watch lo"} {
+		var buf bytes.Buffer
+		if err := sh.Exec(context.Background(), &buf, input); err != nil {
+			t.Fatalf("%q: Exec error: %v", input, err)
+		}
+		if buf.String() != want {
+			t.Errorf("%q output:\n%s\nwant:\n%s", input, buf.String(), want)
+		}
+	}
+}
+
+func TestHelpNotFound(t *testing.T) {
+	sh := helpShell()
+
+	for _, input := range []string{"help xyz", "help watch xyz"} {
+		var buf bytes.Buffer
+		if err := sh.Exec(context.Background(), &buf, input); !errors.Is(err, ErrCmdNotFound) {
+			t.Errorf("%q: err = %v, want %v", input, err, ErrCmdNotFound)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if w := Width(context.Background()); w != DefaultWidth {
+		t.Errorf("Width() = %d, want %d", w, DefaultWidth)
+	}
+	if w := Width(WithWidth(context.Background(), 132)); w != 132 {
+		t.Errorf("Width() = %d, want %d", w, 132)
+	}
+}
@@ -4,42 +4,288 @@
 
 package textcmd
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
 
 // History tracks command history with readline-style cursor
 // navigation.  It is per-session state and is not tied to a
 // Shell.
 type History struct {
-	entries []string
+	entries []HistoryEntry
 	max     int
 	cursor  int
+	evicted int            // evicted counts the removed entries
+	edits   map[int]string // edits are lines edited while navigating
+
+	ignoreSpace bool
+	eraseDups   bool
+	ignore      []*regexp.Regexp
+	filter      func(HistoryEntry) bool
+
+	store HistoryStore
+	err   error
+
+	shared     *SharedHistory
+	sharedNum  int // sharedNum is the shared number of the last entry
+	sharedSeen int // sharedSeen is the last shared number synced
+}
+
+// HistoryEntry is a History entry along with optional details
+// about its execution.
+type HistoryEntry struct {
+	Line     string        // Line is the command line
+	Num      int           // Num is the entry number, set by History
+	Time     time.Time     // Time is when the command started
+	Duration time.Duration // Duration is how long the command ran
+	Err      error         // Err is the error the command returned
+	Session  string        // Session identifies the session that ran it
+}
+
+// HistoryOption configures a History.
+type HistoryOption func(*History)
+
+// WithIgnoreSpace skips adding commands which begin with a
+// space, so they can be kept out of the history.
+func WithIgnoreSpace() HistoryOption {
+	return func(h *History) {
+		h.ignoreSpace = true
+	}
+}
+
+// WithEraseDups removes all previous entries matching a command
+// as it's added, so a repeated command moves to the end.
+func WithEraseDups() HistoryOption {
+	return func(h *History) {
+		h.eraseDups = true
+	}
+}
+
+// WithIgnore skips adding commands which match any of the
+// patterns, such as "login *".  In a pattern * matches any
+// sequence of characters and ? matches any single character.
+// The pattern must match the entire command.
+func WithIgnore(patterns ...string) HistoryOption {
+	return func(h *History) {
+		for _, p := range patterns {
+			h.ignore = append(h.ignore, globRegexp(p))
+		}
+	}
+}
+
+// WithFilter skips adding entries for which f returns false.
+func WithFilter(f func(HistoryEntry) bool) HistoryOption {
+	return func(h *History) {
+		h.filter = f
+	}
+}
+
+// globRegexp compiles a pattern for WithIgnore.
+func globRegexp(pattern string) *regexp.Regexp {
+	var buf strings.Builder
+	buf.WriteString(`^`)
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			buf.WriteString(`.*`)
+		case '?':
+			buf.WriteString(`.`)
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString(`$`)
+
+	return regexp.MustCompile(buf.String())
 }
 
 // NewHistory creates a new History.  max limits the number of
 // entries kept; max <= 0 means unbounded.
-func NewHistory(max int) *History {
-	return &History{max: max}
+func NewHistory(max int, opts ...HistoryOption) *History {
+	h := &History{max: max}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.store != nil {
+		entries, err := h.store.Load()
+		for _, s := range entries {
+			h.add(HistoryEntry{Line: s})
+		}
+		h.setErr(err)
+	}
+
+	return h
 }
 
 // Add appends a command to the history and resets the cursor
-// to the end.  Empty/whitespace-only strings and consecutive
-// duplicates are skipped.
+// to the end.  Empty/whitespace-only strings, consecutive
+// duplicates and commands skipped by the options are not
+// added.  If the history has a store the command is also
+// appended to it.
 func (h *History) Add(s string) {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return
+	h.AddEntry(HistoryEntry{Line: s})
+}
+
+// AddEntry is like Add but also records the details of the
+// entry.  A consecutive duplicate replaces the details of the
+// last entry.  It reports whether the entry was recorded, in
+// which case it's the last entry for SetResult.
+func (h *History) AddEntry(e HistoryEntry) bool {
+	if h.skip(e) {
+		h.Reset()
+		return false
 	}
 
-	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == s {
-		h.cursor = len(h.entries)
-		return
+	if h.shared != nil {
+		h.shared.add(h, e)
+	}
+
+	e, ok := h.add(e)
+	if ok && h.store != nil {
+		h.setErr(h.store.Append(e.Line))
 	}
 
-	h.entries = append(h.entries, s)
+	return e.Line != ""
+}
+
+// skip reports whether the options skip adding an entry.
+func (h *History) skip(e HistoryEntry) bool {
+	if h.ignoreSpace && strings.HasPrefix(e.Line, " ") {
+		return true
+	}
+
+	line := strings.TrimSpace(e.Line)
+	for _, re := range h.ignore {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	return h.filter != nil && !h.filter(e)
+}
+
+// add appends an entry to the history, returning it with the
+// line trimmed and whether it was added.  Any edits are
+// discarded.
+func (h *History) add(e HistoryEntry) (HistoryEntry, bool) {
+	clear(h.edits)
+	e.Line = strings.TrimSpace(e.Line)
+	if e.Line == "" {
+		return e, false
+	}
+
+	if n := len(h.entries); n > 0 && h.entries[n-1].Line == e.Line {
+		e.Num = h.entries[n-1].Num
+		h.entries[n-1] = e
+		h.cursor = n
+		return e, false
+	}
+
+	if h.eraseDups {
+		n := len(h.entries)
+		h.entries = slices.DeleteFunc(h.entries, func(d HistoryEntry) bool {
+			return d.Line == e.Line
+		})
+		h.evicted += n - len(h.entries)
+	}
+
+	e.Num = h.evicted + len(h.entries) + 1
+	h.entries = append(h.entries, e)
 	if h.max > 0 && len(h.entries) > h.max {
+		h.evicted += len(h.entries) - h.max
 		h.entries = h.entries[len(h.entries)-h.max:]
 	}
 	h.cursor = len(h.entries)
+
+	return e, true
+}
+
+// SetResult records the duration and error of the command in
+// the last entry.
+func (h *History) SetResult(d time.Duration, err error) {
+	if n := len(h.entries); n > 0 {
+		h.entries[n-1].Duration, h.entries[n-1].Err = d, err
+	}
+	if h.shared != nil && h.sharedNum > 0 {
+		h.shared.setResult(h.sharedNum, d, err)
+	}
+}
+
+// Entry returns the entry at index i, which ranges from 0 to
+// Len-1.
+func (h *History) Entry(i int) (HistoryEntry, bool) {
+	if i < 0 || i >= len(h.entries) {
+		return HistoryEntry{}, false
+	}
+
+	return h.entries[i], true
+}
+
+// Entries returns the indexes and entries, oldest first.
+func (h *History) Entries() iter.Seq2[int, HistoryEntry] {
+	return func(yield func(int, HistoryEntry) bool) {
+		for i, e := range h.entries {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error encountered loading from or
+// appending to the store.
+func (h *History) Err() error {
+	return h.err
+}
+
+// setErr records err if it's the first error.
+func (h *History) setErr(err error) {
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// Edit records s as the line at the cursor before moving it.
+// At the end (past the last entry) s is the pending line being
+// typed, which Next returns again once it moves past the last
+// entry.  Otherwise s is an edit of the entry, which Prev and
+// Next return in its place until the next Add.
+func (h *History) Edit(s string) {
+	if h.cursor < len(h.entries) && h.entries[h.cursor].Line == s {
+		delete(h.edits, h.cursor)
+		return
+	}
+
+	if h.edits == nil {
+		h.edits = make(map[int]string)
+	}
+	h.edits[h.cursor] = s
+}
+
+// dropPending discards the pending line recorded by Edit.
+func (h *History) dropPending() {
+	delete(h.edits, len(h.entries))
+}
+
+// line returns the entry at index i, or its edit if it has
+// one.  Len is the pending line.
+func (h *History) line(i int) string {
+	if s, ok := h.edits[i]; ok {
+		return s
+	}
+	if i >= len(h.entries) {
+		return ""
+	}
+
+	return h.entries[i].Line
 }
 
 // Prev moves the cursor back and returns the entry.  Returns
@@ -54,25 +300,194 @@ func (h *History) Prev() string {
 		h.cursor--
 	}
 
-	return h.entries[h.cursor]
+	return h.line(h.cursor)
 }
 
-// Next moves the cursor forward and returns the entry.  Returns
-// "" when moving past the last entry.
+// Next moves the cursor forward and returns the entry.  Moving
+// past the last entry returns the pending line recorded by
+// Edit, or "" if there is none.
 func (h *History) Next() string {
-	if len(h.entries) == 0 || h.cursor >= len(h.entries) {
-		return ""
+	if h.cursor < len(h.entries) {
+		h.cursor++
 	}
 
-	h.cursor++
-	if h.cursor >= len(h.entries) {
-		return ""
-	}
+	return h.line(h.cursor)
+}
 
-	return h.entries[h.cursor]
+// atEnd reports whether the cursor is past the last entry.
+func (h *History) atEnd() bool {
+	return h.cursor >= len(h.entries)
 }
 
-// Reset moves the cursor to the end (past the last entry).
+// Reset moves the cursor to the end (past the last entry).  A
+// live shared History first picks up entries added by other
+// sessions.
 func (h *History) Reset() {
+	if h.shared != nil && h.shared.live {
+		h.shared.mu.Lock()
+		h.sync()
+		h.shared.mu.Unlock()
+	}
 	h.cursor = len(h.entries)
 }
+
+// Len returns the number of entries.
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// SearchBack searches backward from index from, inclusive,
+// for the most recent entry containing s.  It returns the
+// entry and its index or "" and -1 if there is none.  To find
+// the next older match search again from one less than the
+// returned index.
+func (h *History) SearchBack(s string, from int) (string, int) {
+	for i := min(from, len(h.entries)-1); i >= 0; i-- {
+		if strings.Contains(h.entries[i].Line, s) {
+			return h.entries[i].Line, i
+		}
+	}
+
+	return "", -1
+}
+
+// SearchForward searches forward from index from, inclusive,
+// for the oldest entry containing s.  It returns the entry and
+// its index or "" and -1 if there is none.  To find the next
+// newer match search again from one more than the returned
+// index.
+func (h *History) SearchForward(s string, from int) (string, int) {
+	for i := max(from, 0); i < len(h.entries); i++ {
+		if strings.Contains(h.entries[i].Line, s) {
+			return h.entries[i].Line, i
+		}
+	}
+
+	return "", -1
+}
+
+// Seek moves the cursor to index i, such as a search match, so
+// Prev and Next continue from there.  i is clamped to the range
+// of entries and Len is the end (past the last entry).
+func (h *History) Seek(i int) {
+	h.cursor = max(0, min(i, len(h.entries)))
+}
+
+// PrevPrefix moves the cursor back to the nearest entry
+// beginning with prefix and returns it.  Entries equal to the
+// one at the cursor are skipped so repeated commands are only
+// offered once in a row.  If there is no such entry the cursor
+// is left unchanged and ok is false.
+func (h *History) PrevPrefix(prefix string) (s string, ok bool) {
+	for i := h.cursor - 1; i >= 0; i-- {
+		if h.matchPrefix(i, prefix) {
+			h.cursor = i
+			return h.line(i), true
+		}
+	}
+
+	return "", false
+}
+
+// NextPrefix moves the cursor forward to the nearest entry
+// beginning with prefix and returns it, skipping entries equal
+// to the one at the cursor.  If there is no such entry the
+// cursor moves to the end and ok is false.
+func (h *History) NextPrefix(prefix string) (s string, ok bool) {
+	for i := h.cursor + 1; i < len(h.entries); i++ {
+		if h.matchPrefix(i, prefix) {
+			h.cursor = i
+			return h.line(i), true
+		}
+	}
+
+	h.cursor = len(h.entries)
+	return "", false
+}
+
+// matchPrefix reports whether entry i begins with prefix and
+// differs from the entry at the cursor.
+func (h *History) matchPrefix(i int, prefix string) bool {
+	if !strings.HasPrefix(h.line(i), prefix) {
+		return false
+	}
+
+	return h.cursor >= len(h.entries) || h.line(i) != h.line(h.cursor)
+}
+
+type historyKey struct{}
+
+// WithHistory returns a copy of ctx carrying the session's
+// history.  Session does this for each command it executes.
+func WithHistory(ctx context.Context, h *History) context.Context {
+	return context.WithValue(ctx, historyKey{}, h)
+}
+
+// HistoryFrom returns the history carried by ctx or nil if
+// there is none.
+func HistoryFrom(ctx context.Context) *History {
+	h, _ := ctx.Value(historyKey{}).(*History)
+	return h
+}
+
+// HistoryCommand returns a command which lists the entries of
+// the session's history with their details.  It is opt-in and
+// is typically registered as:
+//
+//	sh.RegisterCommand(textcmd.HistoryCommand(), "history")
+func HistoryCommand() Command {
+	return Command{
+		Func:    history,
+		Summary: "List command history",
+		Args: []Arg{{
+			Name:        "count",
+			Description: "Only list the most recent count entries",
+			Type:        TypeInt,
+		}},
+	}
+}
+
+func history(ctx context.Context, rw io.ReadWriter, _ ...string) error {
+	h := HistoryFrom(ctx)
+	if h == nil {
+		return nil
+	}
+
+	from := 0
+	if count := ValuesFrom(ctx).Int("count"); count > 0 {
+		from = max(0, h.Len()-count)
+	}
+
+	sessions := false
+	for _, e := range h.Entries() {
+		sessions = sessions || e.Session != ""
+	}
+
+	for i, e := range h.Entries() {
+		if i < from {
+			continue
+		}
+
+		var when, dur string
+		if !e.Time.IsZero() {
+			when = e.Time.Format(time.DateTime)
+		}
+		if e.Duration > 0 {
+			dur = e.Duration.Round(time.Millisecond).String()
+		}
+		status := "ok"
+		if e.Err != nil {
+			status = "error"
+		} else if e.Time.IsZero() {
+			status = ""
+		}
+
+		fmt.Fprintf(rw, "%5d  %-19s  %8s  %-5s  ", e.Num, when, dur, status)
+		if sessions {
+			fmt.Fprintf(rw, "%-21s  ", e.Session)
+		}
+		fmt.Fprintln(rw, e.Line)
+	}
+
+	return nil
+}
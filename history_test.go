@@ -4,7 +4,15 @@
 
 package textcmd
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestHistoryPrevNext(t *testing.T) {
 	h := NewHistory(0)
@@ -136,3 +144,245 @@ func TestHistoryReset(t *testing.T) {
 		t.Errorf("Prev() after Reset = %q, want %q", got, "second")
 	}
 }
+
+func TestHistoryOptions(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts []HistoryOption
+		want []string
+	}{
+		{"default", nil, []string{"date", "login admin secret", "uptime", "date", "lamps on", "date"}},
+		{"ignore space", []HistoryOption{WithIgnoreSpace()}, []string{"login admin secret", "uptime", "date", "lamps on", "date"}},
+		{"erase dups", []HistoryOption{WithEraseDups()}, []string{"login admin secret", "uptime", "lamps on", "date"}},
+		{"ignore", []HistoryOption{WithIgnore("login *", "lamps o?")}, []string{"date", "uptime", "date"}},
+		{"filter", []HistoryOption{WithFilter(func(e HistoryEntry) bool {
+			return !strings.Contains(e.Line, "date")
+		})}, []string{"login admin secret", "uptime", "lamps on"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHistory(0, test.opts...)
+			for _, s := range []string{" date", "login admin secret", "uptime", "date", "lamps on", "date"} {
+				h.Add(s)
+			}
+
+			var got []string
+			for _, e := range h.Entries() {
+				got = append(got, e.Line)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("entries %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHistoryEraseDupsNum(t *testing.T) {
+	h := NewHistory(0, WithEraseDups())
+	for _, s := range []string{"uptime", "date", "uptime", "version"} {
+		h.Add(s)
+	}
+
+	var nums []int
+	for _, e := range h.Entries() {
+		nums = append(nums, e.Num)
+	}
+	if want := []int{2, 3, 4}; !slices.Equal(nums, want) {
+		t.Errorf("nums %v, want %v", nums, want)
+	}
+
+	if got, err := h.Expand("!3"); err != nil || got != "uptime" {
+		t.Errorf("Expand(%q) = %q, %v, want %q", "!3", got, err, "uptime")
+	}
+}
+
+func TestHistoryEdit(t *testing.T) {
+	h := NewHistory(0)
+	h.Add("first")
+	h.Add("second")
+
+	// Stash the pending line and edit an entry.
+	h.Edit("pend")
+	h.Prev()
+	h.Edit("second edited")
+	h.Prev()
+	if got := h.Next(); got != "second edited" {
+		t.Errorf("Next() = %q, want %q", got, "second edited")
+	}
+	if got := h.Next(); got != "pend" {
+		t.Errorf("Next() past end = %q, want %q", got, "pend")
+	}
+	if got := h.Prev(); got != "second edited" {
+		t.Errorf("Prev() = %q, want %q", got, "second edited")
+	}
+
+	// Restoring an entry's original line forgets the edit.
+	h.Edit("second")
+	h.Next()
+	if got := h.Prev(); got != "second" {
+		t.Errorf("Prev() = %q, want %q", got, "second")
+	}
+
+	// Add discards the edits.
+	h.Edit("second edited")
+	h.Add("third")
+	h.Prev()
+	if got := h.Prev(); got != "second" {
+		t.Errorf("Prev() after Add = %q, want %q", got, "second")
+	}
+	h.Reset()
+	if got := h.Next(); got != "" {
+		t.Errorf("Next() after Add = %q, want %q", got, "")
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := NewHistory(0)
+	for _, s := range []string{"watch log debug", "date", "watch loops", "lamps on", "watch conditions"} {
+		h.Add(s)
+	}
+
+	// Repeat backward searches to find older matches.
+	var got []int
+	for i := h.Len(); ; {
+		_, i = h.SearchBack("watch", i-1)
+		if i < 0 {
+			break
+		}
+		got = append(got, i)
+	}
+	if fmt.Sprint(got) != "[4 2 0]" {
+		t.Errorf("backward matches = %v, want %v", got, "[4 2 0]")
+	}
+
+	if e, i := h.SearchBack("lo", h.Len()); e != "watch loops" || i != 2 {
+		t.Errorf("SearchBack() = %q, %d, want %q, %d", e, i, "watch loops", 2)
+	}
+	if e, i := h.SearchForward("lo", 1); e != "watch loops" || i != 2 {
+		t.Errorf("SearchForward() = %q, %d, want %q, %d", e, i, "watch loops", 2)
+	}
+	if e, i := h.SearchForward("lo", 3); e != "" || i != -1 {
+		t.Errorf("SearchForward() past last match = %q, %d, want %q, %d", e, i, "", -1)
+	}
+	if e, i := h.SearchBack("xyz", 99); e != "" || i != -1 {
+		t.Errorf("SearchBack() no match = %q, %d, want %q, %d", e, i, "", -1)
+	}
+
+	// Navigation continues from the match.
+	h.Seek(2)
+	if got := h.Prev(); got != "date" {
+		t.Errorf("Prev() after Seek = %q, want %q", got, "date")
+	}
+	h.Seek(2)
+	if got := h.Next(); got != "lamps on" {
+		t.Errorf("Next() after Seek = %q, want %q", got, "lamps on")
+	}
+}
+
+func TestHistoryPrefix(t *testing.T) {
+	h := NewHistory(0)
+	for _, s := range []string{"watch log debug", "date", "watch loops", "watch log debug", "lamps on"} {
+		h.Add(s)
+	}
+
+	for _, test := range []struct {
+		op    string
+		entry string
+		ok    bool
+	}{
+		{"prev", "watch log debug", true},
+		{"prev", "watch loops", true},
+		{"prev", "watch log debug", true},
+		{"prev", "", false},
+		{"next", "watch loops", true},
+		{"next", "watch log debug", true},
+		{"next", "", false},
+		{"next", "", false},
+		{"prev", "watch log debug", true},
+	} {
+		var entry string
+		var ok bool
+		if test.op == "prev" {
+			entry, ok = h.PrevPrefix("watch ")
+		} else {
+			entry, ok = h.NextPrefix("watch ")
+		}
+		if entry != test.entry || ok != test.ok {
+			t.Errorf("%s = %q, %t, want %q, %t", test.op, entry, ok, test.entry, test.ok)
+		}
+	}
+
+	// Unfiltered navigation continues from the same cursor.
+	if got := h.Next(); got != "lamps on" {
+		t.Errorf("Next() = %q, want %q", got, "lamps on")
+	}
+	if got := h.Prev(); got != "watch log debug" {
+		t.Errorf("Prev() = %q, want %q", got, "watch log debug")
+	}
+}
+
+func TestHistoryEntries(t *testing.T) {
+	h := NewHistory(2)
+	t0 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.AddEntry(HistoryEntry{Line: "date", Time: t0, Session: "a"})
+	h.SetResult(time.Second, nil)
+	h.Add("uptime")
+	h.AddEntry(HistoryEntry{Line: "uptime", Time: t0.Add(time.Minute), Session: "b"})
+	h.SetResult(2*time.Second, ErrCmdNotFound)
+	h.Add("lamps on")
+
+	// "date" is evicted but numbering continues.
+	var got []HistoryEntry
+	for i, e := range h.Entries() {
+		if e2, ok := h.Entry(i); !ok || e2 != e {
+			t.Errorf("Entry(%d) = %v, %t, want %v", i, e2, ok, e)
+		}
+		got = append(got, e)
+	}
+	want := []HistoryEntry{
+		{Line: "uptime", Num: 2, Time: t0.Add(time.Minute), Duration: 2 * time.Second, Err: ErrCmdNotFound, Session: "b"},
+		{Line: "lamps on", Num: 3},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+
+	if _, ok := h.Entry(2); ok {
+		t.Error("Entry(2) out of range ok")
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	var sh Shell
+	sh.RegisterCommand(HistoryCommand(), "history")
+
+	h := NewHistory(0)
+	t0 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.AddEntry(HistoryEntry{Line: "date", Time: t0})
+	h.SetResult(1500*time.Millisecond, nil)
+	h.AddEntry(HistoryEntry{Line: "xyz", Time: t0.Add(time.Second)})
+	h.SetResult(0, ErrCmdNotFound)
+	h.Add("uptime")
+
+	var buf bytes.Buffer
+	ctx := WithHistory(context.Background(), h)
+	if err := sh.Exec(ctx, &buf, "history"); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	want := "" +
+		"    1  2020-01-02 03:04:05      1.5s  ok     date\n" +
+		"    2  2020-01-02 03:04:06            error  xyz\n" +
+		"    3                                        uptime\n"
+	if buf.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	h.AddEntry(HistoryEntry{Line: "history 1", Session: "127.0.0.1:23"})
+	if err := sh.Exec(ctx, &buf, "history 1"); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	want = "    4                                        127.0.0.1:23           history 1\n"
+	if buf.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+// Package telnet implements the server side of the telnet protocol (RFC 854)
+// needed to present a character at a time, line editing session.  It
+// negotiates ECHO, SUPPRESS-GO-AHEAD, NAWS window size and TTYPE terminal
+// type and filters protocol commands out of the data stream.
+package telnet
+
+import (
+	"bufio"
+	"net"
+	"sync"
+)
+
+// Commands.
+const (
+	SE   = 240 // End of subnegotiation
+	NOP  = 241 // No operation
+	IP   = 244 // Interrupt process
+	AYT  = 246 // Are you there
+	GA   = 249 // Go ahead
+	SB   = 250 // Begin subnegotiation
+	WILL = 251
+	WONT = 252
+	DO   = 253
+	DONT = 254
+	IAC  = 255 // Interpret as command
+)
+
+// Options.
+const (
+	OptEcho  = 1
+	OptSGA   = 3  // Suppress go ahead
+	OptTTYPE = 24 // Terminal type
+	OptNAWS  = 31 // Negotiate about window size
+)
+
+// maxSubneg is the longest subnegotiation processed.  Those of the supported
+// options are only a few bytes, longer ones are discarded.
+const maxSubneg = 64
+
+// TTYPE subnegotiation commands.
+const (
+	ttypeIS   = 0
+	ttypeSEND = 1
+)
+
+// Conn is a telnet connection.  Reads return only data, with protocol
+// commands handled transparently, and writes escape any IAC bytes.
+type Conn struct {
+	net.Conn
+
+	r   *bufio.Reader
+	wmu sync.Mutex // wmu serializes writes
+
+	mu     sync.Mutex // mu guards the fields below
+	will   [256]bool  // will are the options enabled locally
+	do     [256]bool  // do are the options enabled remotely
+	width  int
+	height int
+	term   string
+}
+
+// NewConn creates a new telnet connection over c.
+func NewConn(c net.Conn) *Conn {
+	return &Conn{Conn: c, r: bufio.NewReader(c)}
+}
+
+// Negotiate asks the client to enter character at a time mode with the
+// server echoing and to report its window size and terminal type.  The
+// client's answers are processed as they are read.
+func (c *Conn) Negotiate() error {
+	c.mu.Lock()
+	c.will[OptEcho], c.will[OptSGA] = true, true
+	c.do[OptSGA], c.do[OptNAWS], c.do[OptTTYPE] = true, true, true
+	c.mu.Unlock()
+
+	return c.command(
+		IAC, WILL, OptEcho,
+		IAC, WILL, OptSGA,
+		IAC, DO, OptSGA,
+		IAC, DO, OptNAWS,
+		IAC, DO, OptTTYPE,
+	)
+}
+
+// Width returns the client's window width or 0 if it's unknown.
+func (c *Conn) Width() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.width
+}
+
+// Height returns the client's window height or 0 if it's unknown.
+func (c *Conn) Height() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.height
+}
+
+// TermType returns the client's terminal type or "" if it's unknown.
+func (c *Conn) TermType() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.term
+}
+
+// Read reads data from the connection.  It blocks until at least one data
+// byte is available, processing any protocol commands along the way.  An
+// interrupt process command is returned as Ctrl-C.
+func (c *Conn) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && c.r.Buffered() == 0 {
+			break
+		}
+
+		var b byte
+		b, err = c.r.ReadByte()
+		if err != nil {
+			break
+		}
+		if b != IAC {
+			p[n] = b
+			n++
+			continue
+		}
+
+		b, err = c.r.ReadByte()
+		if err != nil {
+			break
+		}
+		switch b {
+		case IAC:
+			p[n] = IAC
+			n++
+		case IP:
+			p[n] = 0x03
+			n++
+		case AYT:
+			_, err = c.Write([]byte("\r\n[yes]\r\n"))
+		case WILL, WONT, DO, DONT:
+			var opt byte
+			opt, err = c.r.ReadByte()
+			if err == nil {
+				err = c.negotiate(b, opt)
+			}
+		case SB:
+			err = c.subnegotiate()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return
+}
+
+// Write writes data to the connection, escaping IAC bytes.
+func (c *Conn) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p))
+	for _, b := range p {
+		if b == IAC {
+			buf = append(buf, IAC)
+		}
+		buf = append(buf, b)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// command writes a protocol command.
+func (c *Conn) command(b ...byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(b)
+
+	return err
+}
+
+// negotiate answers an option negotiation request.  Only requests that
+// change an option's state are answered so negotiation can't loop.
+func (c *Conn) negotiate(verb, opt byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch verb {
+	case DO:
+		if c.will[opt] {
+			return nil
+		}
+		if opt == OptEcho || opt == OptSGA {
+			c.will[opt] = true
+			return c.command(IAC, WILL, opt)
+		}
+		return c.command(IAC, WONT, opt)
+	case DONT:
+		if !c.will[opt] {
+			return nil
+		}
+		c.will[opt] = false
+		return c.command(IAC, WONT, opt)
+	case WILL:
+		switch opt {
+		case OptTTYPE:
+			// Ask for the terminal type now that the client
+			// agreed to send it.
+			c.do[opt] = true
+			return c.command(IAC, SB, OptTTYPE, ttypeSEND, IAC, SE)
+		case OptSGA, OptNAWS:
+			if c.do[opt] {
+				return nil
+			}
+			c.do[opt] = true
+			return c.command(IAC, DO, opt)
+		}
+		return c.command(IAC, DONT, opt)
+	case WONT:
+		if !c.do[opt] {
+			return nil
+		}
+		c.do[opt] = false
+		return c.command(IAC, DONT, opt)
+	}
+
+	return nil
+}
+
+// subnegotiate reads a subnegotiation, up to IAC SE, and processes it.
+// One longer than maxSubneg is read but discarded.
+func (c *Conn) subnegotiate() error {
+	var buf []byte
+	long := false
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == IAC {
+			b, err = c.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if b == SE {
+				break
+			}
+		}
+		if len(buf) == maxSubneg {
+			long = true
+			continue
+		}
+		buf = append(buf, b)
+	}
+	if len(buf) == 0 || long {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch buf[0] {
+	case OptNAWS:
+		if len(buf) == 5 {
+			c.width = int(buf[1])<<8 | int(buf[2])
+			c.height = int(buf[3])<<8 | int(buf[4])
+		}
+	case OptTTYPE:
+		if len(buf) > 1 && buf[1] == ttypeIS {
+			c.term = string(buf[2:])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeConn is a connection with scripted input.
+type fakeConn struct {
+	net.Conn
+	r io.Reader
+	w bytes.Buffer
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func TestNegotiate(t *testing.T) {
+	fc := &fakeConn{r: strings.NewReader("")}
+	c := NewConn(fc)
+	if err := c.Negotiate(); err != nil {
+		t.Fatalf("Negotiate error: %v", err)
+	}
+
+	want := []byte{
+		IAC, WILL, OptEcho,
+		IAC, WILL, OptSGA,
+		IAC, DO, OptSGA,
+		IAC, DO, OptNAWS,
+		IAC, DO, OptTTYPE,
+	}
+	if !bytes.Equal(fc.w.Bytes(), want) {
+		t.Errorf("sent %v, want %v", fc.w.Bytes(), want)
+	}
+}
+
+func TestRead(t *testing.T) {
+	input := []byte{'a', IAC, IAC, 'b'}
+	input = append(input, IAC, DO, OptEcho, IAC, WILL, OptSGA, IAC, WILL, OptNAWS)
+	input = append(input, IAC, SB, OptNAWS, 0, 132, 0, IAC, IAC, IAC, SE)
+	input = append(input, IAC, WILL, OptTTYPE)
+	input = append(input, IAC, SB, OptTTYPE, ttypeIS, 'x', 't', 'e', 'r', 'm', IAC, SE)
+	input = append(input, IAC, DO, 99, IAC, WILL, 98, IAC, NOP, IAC, IP, 'c')
+
+	fc := &fakeConn{r: bytes.NewReader(input)}
+	c := NewConn(fc)
+	c.Negotiate()
+	fc.w.Reset()
+
+	data, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("ReadAll error: %v", err)
+	}
+	if want := []byte{'a', IAC, 'b', 0x03, 'c'}; !bytes.Equal(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+
+	if c.Width() != 132 || c.Height() != IAC {
+		t.Errorf("window = %dx%d, want %dx%d", c.Width(), c.Height(), 132, IAC)
+	}
+	if c.TermType() != "xterm" {
+		t.Errorf("terminal type = %q, want %q", c.TermType(), "xterm")
+	}
+
+	// Requests for enabled options are not answered again and unsupported
+	// options are refused.
+	want := []byte{
+		IAC, SB, OptTTYPE, ttypeSEND, IAC, SE,
+		IAC, WONT, 99,
+		IAC, DONT, 98,
+	}
+	if !bytes.Equal(fc.w.Bytes(), want) {
+		t.Errorf("sent %v, want %v", fc.w.Bytes(), want)
+	}
+}
+
+func TestReadLongSubneg(t *testing.T) {
+	input := []byte{IAC, SB, OptTTYPE, ttypeIS}
+	input = append(input, bytes.Repeat([]byte{'x'}, 1<<20)...)
+	input = append(input, IAC, SE, 'a')
+
+	c := NewConn(&fakeConn{r: bytes.NewReader(input)})
+	data, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("ReadAll error: %v", err)
+	}
+	if want := []byte{'a'}; !bytes.Equal(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+	if c.TermType() != "" {
+		t.Errorf("terminal type = %.20q..., want none", c.TermType())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	fc := &fakeConn{r: strings.NewReader("")}
+	c := NewConn(fc)
+
+	n, err := c.Write([]byte{'a', IAC, 'b'})
+	if err != nil || n != 3 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if want := []byte{'a', IAC, IAC, 'b'}; !bytes.Equal(fc.w.Bytes(), want) {
+		t.Errorf("sent %v, want %v", fc.w.Bytes(), want)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"fmt"
 	"iter"
+	"maps"
 	"sort"
 	"strings"
 )
@@ -49,6 +50,67 @@ func (n *Node) Add(key string, val any) {
 	cur.Val = val
 }
 
+// With returns a copy of the tree with a key and value added, leaving n
+// unchanged.  Only the nodes along the key are copied and the rest are shared
+// with n, so n must not be modified afterwards.  A nil n is an empty tree.
+func (n *Node) With(key string, val any) *Node {
+	root := n.clone()
+	cur := root
+	for _, c := range key {
+		child := cur.children[c].clone()
+		child.char = c
+		cur.children[c] = child
+		cur = child
+	}
+	cur.Val = val
+
+	return root
+}
+
+// Without returns a copy of the tree with a key removed along with the key's
+// value, leaving n unchanged.  Nodes left with neither a value nor children
+// are pruned.  If the key isn't in the tree n itself is returned with a nil
+// value.
+func (n *Node) Without(key string) (*Node, any) {
+	cur := n.Get(key)
+	if cur == nil || cur.Val == nil {
+		return n, nil
+	}
+
+	return n.without([]rune(key)), cur.Val
+}
+
+// without returns a copy of the node with the remainder of a key removed.
+func (n *Node) without(key []rune) *Node {
+	c := n.clone()
+	if len(key) == 0 {
+		c.Val = nil
+		return c
+	}
+
+	child := n.children[key[0]].without(key[1:])
+	if child.Val == nil && len(child.children) == 0 {
+		delete(c.children, key[0])
+	} else {
+		c.children[key[0]] = child
+	}
+
+	return c
+}
+
+// clone returns a copy of the node with its own children map.  A nil node
+// clones to an empty node.
+func (n *Node) clone() *Node {
+	if n == nil {
+		return &Node{children: make(map[rune]*Node)}
+	}
+
+	c := &Node{char: n.char, Val: n.Val, children: make(map[rune]*Node, len(n.children)+1)}
+	maps.Copy(c.children, n.children)
+
+	return c
+}
+
 // Children returns the immediate child nodes optionally sorted in
 // alphabetical order.
 func (n *Node) Children(sorted bool) []*Node {
@@ -161,6 +223,64 @@ func (n Node) Match(key string) iter.Seq[string] {
 	}
 }
 
+// Near returns the keys within max edits of key and their edit distances,
+// in alphabetical order.  Distance is measured as the Levenshtein distance
+// between a key and the closest leading part of key that ends before a sep
+// (or all of key), so trailing words such as arguments are disregarded.
+func (n *Node) Near(key string, sep rune, max int) iter.Seq2[string, int] {
+	return func(yield func(string, int) bool) {
+		k := []rune(key)
+		row := make([]int, len(k)+1)
+		for j := range row {
+			row[j] = j
+		}
+
+		n.near("", k, sep, max, row, yield)
+	}
+}
+
+// near walks the node's children computing the next row of the edit
+// distance matrix for each.  It returns false if yield asked to stop.
+func (n *Node) near(key string, k []rune, sep rune, max int, prev []int, yield func(string, int) bool) bool {
+	for _, child := range n.Children(true) {
+		row := make([]int, len(prev))
+		row[0] = prev[0] + 1
+		low := row[0]
+		for j := 1; j < len(row); j++ {
+			cost := 1
+			if k[j-1] == child.char {
+				cost = 0
+			}
+			row[j] = min(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			low = min(low, row[j])
+		}
+
+		// No further characters can bring the distance back under max.
+		if low > max {
+			continue
+		}
+
+		ckey := key + string(child.char)
+		if child.Val != nil {
+			d := max + 1
+			for j := 1; j < len(row); j++ {
+				if j == len(k) || k[j] == sep {
+					d = min(d, row[j])
+				}
+			}
+			if d <= max && !yield(ckey, d) {
+				return false
+			}
+		}
+
+		if !child.near(ckey, k, sep, max, row, yield) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // String returns a pretty-printed string of the node and all its children.
 func (n Node) String() string {
 	var buf bytes.Buffer
@@ -168,6 +288,42 @@ func (n Node) String() string {
 	return buf.String()
 }
 
+// Words returns the words beginning with prefix that follow the node along
+// with the node ending each word, in alphabetical order.  A word ends at a
+// terminal node or where it is followed by sep, so if prefix is itself a
+// word it is always returned first.
+func (n *Node) Words(prefix string, sep rune) iter.Seq2[string, *Node] {
+	return func(yield func(string, *Node) bool) {
+		cur := n.Get(prefix)
+		if cur == nil {
+			return
+		}
+
+		cur.words(prefix, sep, yield)
+	}
+}
+
+// words walks the node and its children, stopping at sep, and calls yield
+// for every word ending.  It returns false if yield asked to stop.
+func (n *Node) words(word string, sep rune, yield func(string, *Node) bool) bool {
+	if word != "" && (n.Val != nil || n.children[sep] != nil) {
+		if !yield(word, n) {
+			return false
+		}
+	}
+
+	for _, child := range n.Children(true) {
+		if child.char == sep {
+			continue
+		}
+		if !child.words(word+string(child.char), sep, yield) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type walkFunc func(string, *Node) bool
 
 func (n *Node) walk(key string, onlyUniq bool, fAll bool, f walkFunc) {
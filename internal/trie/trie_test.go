@@ -192,6 +192,64 @@ func TestNode_Add(t *testing.T) {
 	testTree()
 }
 
+func TestNode_With(t *testing.T) {
+	var n *Node
+	for _, k := range testKeys {
+		n = n.With(k, testVal(k))
+	}
+	if got, want := n.String(), testTree().String(); got != want {
+		t.Errorf("With tree is:\n%s\nbut expected:\n%s", got, want)
+	}
+
+	// The original tree is unchanged.
+	m := n.With("watch lamps", testVal("watch lamps")).With("date", "new date")
+	if cur := n.Get("watch lamps"); cur != nil {
+		t.Errorf("original tree has %q", "watch lamps")
+	}
+	if cur := n.Get("date"); cur.Val != testVal("date") {
+		t.Errorf("original %q value is %v but expected %v", "date", cur.Val, testVal("date"))
+	}
+	if cur := m.Get("watch lamps"); cur == nil || cur.Val != testVal("watch lamps") {
+		t.Errorf("new tree is missing %q", "watch lamps")
+	}
+	if cur := m.Get("date"); cur.Val != "new date" {
+		t.Errorf("new %q value is %v but expected %v", "date", cur.Val, "new date")
+	}
+}
+
+func TestNode_Without(t *testing.T) {
+	n := testTree()
+
+	m, val := n.Without("watch log trace")
+	if val != testVal("watch log trace") {
+		t.Errorf("removed value is %v but expected %v", val, testVal("watch log trace"))
+	}
+	if cur := m.Get("watch log t"); cur != nil {
+		t.Errorf("%q was not pruned", "watch log t")
+	}
+	if cur := m.Get("watch log debug"); cur == nil || cur.Val != testVal("watch log debug") {
+		t.Errorf("%q was removed", "watch log debug")
+	}
+	if cur := n.Get("watch log trace"); cur == nil || cur.Val != testVal("watch log trace") {
+		t.Errorf("original tree is missing %q", "watch log trace")
+	}
+
+	// Removing a key with children keeps them.
+	m, _ = m.With("watch", "watch").Without("watch")
+	if cur := m.Get("watch"); cur == nil || cur.Val != nil {
+		t.Errorf("%q was not removed", "watch")
+	}
+	if cur := m.Get("watch loops"); cur == nil {
+		t.Errorf("%q was removed", "watch loops")
+	}
+
+	for _, key := range []string{"watch", "watch log", "foo"} {
+		if m2, val := n.Without(key); m2 != n || val != nil {
+			t.Errorf("Without(%q) removed a value", key)
+		}
+	}
+}
+
 func TestNode_Find(t *testing.T) {
 	n := testTree()
 	for _, test := range []struct {
@@ -291,3 +349,69 @@ func TestNode_Match(t *testing.T) {
 		})
 	}
 }
+
+func TestNode_Words(t *testing.T) {
+	n := testTree()
+	for _, test := range []struct {
+		node  string
+		key   string
+		words []string
+	}{
+		{"", "lo", []string{"logout", "loop"}},
+		{"", "lamps", []string{"lamps"}},
+		{"", "w", []string{"watch", "whoami"}},
+		{"watch ", "lo", []string{"log", "loops"}},
+		{"watch log ", "", []string{"debug", "trace"}},
+		{"", "z", []string{}},
+	} {
+		t.Run(test.node+test.key, func(t *testing.T) {
+			i := 0
+			for w, cur := range n.Get(test.node).Words(test.key, ' ') {
+				if i >= len(test.words) {
+					t.Errorf("%q: unexpected index %d %q", test.key, i, w)
+				} else if w != test.words[i] {
+					t.Errorf("%q: mismatched index %d %q != %q", test.key, i, w, test.words[i])
+				}
+				if cur.Val == nil && cur.Get(" ") == nil {
+					t.Errorf("%q: word %q does not end at a terminal or separator", test.key, w)
+				}
+
+				i++
+			}
+			if i < len(test.words) {
+				t.Errorf("%q: missing %q", test.key, test.words[i:])
+			}
+		})
+	}
+}
+
+func TestNode_Near(t *testing.T) {
+	n := testTree()
+	for _, test := range []struct {
+		key  string
+		max  int
+		keys []string
+		dist []int
+	}{
+		{"tiem", 2, []string{"time"}, []int{2}},
+		{"uptmie", 2, []string{"uptime"}, []int{2}},
+		{"hlep", 1, []string{}, []int{}},
+		{"halp", 1, []string{"help"}, []int{1}},
+		{"lamps of", 1, []string{"lamps off", "lamps on"}, []int{1, 1}},
+		{"wach loops now", 1, []string{"watch loops"}, []int{1}},
+		{"version", 0, []string{"version"}, []int{0}},
+		{"zzzzzz", 2, []string{}, []int{}},
+	} {
+		t.Run(test.key, func(t *testing.T) {
+			var keys []string
+			var dist []int
+			for k, d := range n.Near(test.key, ' ', test.max) {
+				keys = append(keys, k)
+				dist = append(dist, d)
+			}
+			if fmt.Sprint(keys) != fmt.Sprint(test.keys) || fmt.Sprint(dist) != fmt.Sprint(test.dist) {
+				t.Errorf("%q: got %q %v, want %q %v", test.key, keys, dist, test.keys, test.dist)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import "bufio"
+
+// key is an editing key decoded from the input stream.
+type key int
+
+// Editing keys.  keyRune is a printable character.
+const (
+	keyNone key = iota
+	keyRune
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyKillEnd    // Ctrl-K
+	keyKillStart  // Ctrl-U
+	keyKillWord   // Ctrl-W
+	keyInterrupt  // Ctrl-C
+	keyEOF        // Ctrl-D
+	keyClear      // Ctrl-L
+	keySearchBack // Ctrl-R
+	keySearchFwd  // Ctrl-S
+	keyEscape
+)
+
+// ctrlKeys maps control characters to editing keys.
+var ctrlKeys = map[rune]key{
+	0x01: keyHome,       // Ctrl-A
+	0x02: keyLeft,       // Ctrl-B
+	0x03: keyInterrupt,  // Ctrl-C
+	0x04: keyEOF,        // Ctrl-D
+	0x05: keyEnd,        // Ctrl-E
+	0x06: keyRight,      // Ctrl-F
+	0x08: keyBackspace,  // Ctrl-H
+	0x09: keyTab,        // Ctrl-I
+	0x0a: keyEnter,      // Ctrl-J
+	0x0b: keyKillEnd,    // Ctrl-K
+	0x0c: keyClear,      // Ctrl-L
+	0x0d: keyEnter,      // Ctrl-M
+	0x0e: keyDown,       // Ctrl-N
+	0x10: keyUp,         // Ctrl-P
+	0x12: keySearchBack, // Ctrl-R
+	0x13: keySearchFwd,  // Ctrl-S
+	0x15: keyKillStart,  // Ctrl-U
+	0x17: keyKillWord,   // Ctrl-W
+	0x7f: keyBackspace,  // DEL
+}
+
+// csiKeys maps the final byte of ANSI/VT100 cursor key sequences, such as
+// "ESC [ A" or "ESC O A", to editing keys.
+var csiKeys = map[byte]key{
+	'A': keyUp,
+	'B': keyDown,
+	'C': keyRight,
+	'D': keyLeft,
+	'F': keyEnd,
+	'H': keyHome,
+}
+
+// tildeKeys maps the numeric parameter of VT220 style "ESC [ n ~" sequences
+// to editing keys.
+var tildeKeys = map[int]key{
+	1: keyHome,
+	3: keyDelete,
+	4: keyEnd,
+	5: keyPageUp,
+	6: keyPageDown,
+	7: keyHome,
+	8: keyEnd,
+}
+
+// keyReader decodes editing keys from a byte stream.
+type keyReader struct {
+	r *bufio.Reader
+
+	// cr is set after a carriage return so a following line feed or
+	// null, as sent by many terminals and telnet clients, is ignored.
+	cr bool
+}
+
+// readKey returns the next editing key.  For keyRune the character is also
+// returned.  Unrecognized control characters and escape sequences are
+// returned as keyNone.
+func (kr *keyReader) readKey() (key, rune, error) {
+	c, _, err := kr.r.ReadRune()
+	if err != nil {
+		return keyNone, 0, err
+	}
+
+	cr := kr.cr
+	kr.cr = c == '\r'
+	if cr && (c == '\n' || c == 0) {
+		return keyNone, 0, nil
+	}
+
+	if c == 0x1b {
+		return kr.readEscape()
+	}
+	if k, ok := ctrlKeys[c]; ok {
+		return k, c, nil
+	}
+	if c < 0x20 {
+		return keyNone, c, nil
+	}
+
+	return keyRune, c, nil
+}
+
+// readEscape decodes the remainder of an escape sequence.  A lone escape
+// is only recognized if nothing else is buffered behind it.
+func (kr *keyReader) readEscape() (key, rune, error) {
+	if kr.r.Buffered() == 0 {
+		return keyEscape, 0x1b, nil
+	}
+
+	b, err := kr.r.ReadByte()
+	if err != nil {
+		return keyNone, 0, err
+	}
+
+	switch b {
+	case 'O':
+		b, err = kr.r.ReadByte()
+		if err != nil {
+			return keyNone, 0, err
+		}
+		return csiKeys[b], 0, nil
+	case '[':
+	default:
+		return keyNone, 0, nil
+	}
+
+	// Control sequence: parameter bytes followed by a final byte.
+	n := 0
+	for {
+		b, err = kr.r.ReadByte()
+		if err != nil {
+			return keyNone, 0, err
+		}
+
+		switch {
+		case b >= '0' && b <= '9':
+			n = n*10 + int(b-'0')
+		case b == '~':
+			return tildeKeys[n], 0, nil
+		case b >= 0x40 && b <= 0x7e:
+			return csiKeys[b], 0, nil
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Syntax errors.
+var (
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	ErrTrailingEscape    = errors.New("trailing backslash")
+)
+
+// SyntaxError describes a problem tokenizing a command line.
+type SyntaxError struct {
+	Input  string // Input is the line being tokenized
+	Offset int    // Offset is the byte offset of the problem
+	Err    error  // Err is ErrUnterminatedQuote or ErrTrailingEscape
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// token is a word from a command line along with the byte span it occupied
+// in the original input, including any quotes.
+type token struct {
+	val        string
+	start, end int
+}
+
+// lex splits s into words using POSIX shell-like quoting rules:
+//
+//   - Unquoted whitespace separates words.
+//   - Single quotes preserve everything up to the next single quote.
+//   - Double quotes preserve everything up to the next unescaped double
+//     quote.  Within them a backslash only escapes " and \.
+//   - Outside of quotes a backslash preserves the next character.
+//
+// On error the words lexed so far are returned, including the partial last
+// word, so callers such as completion can still make use of them.
+func lex(s string) (tokens []token, err error) {
+	var (
+		buf    strings.Builder
+		inWord bool
+		start  int
+		quote  rune
+		qpos   int
+	)
+
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+				buf.WriteByte(s[i+1])
+				size++
+			} else {
+				buf.WriteRune(c)
+			}
+		case unicode.IsSpace(c):
+			if inWord {
+				tokens = append(tokens, token{val: buf.String(), start: start, end: i})
+				buf.Reset()
+				inWord = false
+			}
+		default:
+			if !inWord {
+				inWord, start = true, i
+			}
+
+			switch c {
+			case '\'', '"':
+				quote, qpos = c, i
+			case '\\':
+				if i+size >= len(s) {
+					err = &SyntaxError{Input: s, Offset: i, Err: ErrTrailingEscape}
+					break
+				}
+				c, n := utf8.DecodeRuneInString(s[i+size:])
+				buf.WriteRune(c)
+				size += n
+			default:
+				buf.WriteRune(c)
+			}
+		}
+
+		i += size
+	}
+
+	if quote != 0 {
+		err = &SyntaxError{Input: s, Offset: qpos, Err: ErrUnterminatedQuote}
+	}
+	if inWord {
+		tokens = append(tokens, token{val: buf.String(), start: start, end: len(s)})
+	}
+
+	return
+}
+
+// Split splits s into words the same way Exec does, honoring single quotes,
+// double quotes and backslash escapes.
+func Split(s string) ([]string, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return words(tokens), nil
+}
+
+// words returns the values of tokens.
+func words(tokens []token) []string {
+	w := make([]string, len(tokens))
+	for i := range tokens {
+		w[i] = tokens[i].val
+	}
+
+	return w
+}
+
+// quote returns s escaped so that lex reads it back as a single word.
+func quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	var buf strings.Builder
+	for _, c := range s {
+		if unicode.IsSpace(c) || c == '\'' || c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(c)
+	}
+
+	return buf.String()
+}
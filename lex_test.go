@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		words []string
+		err   error
+	}{
+		{"empty", "", []string{}, nil},
+		{"blank", " \t ", []string{}, nil},
+		{"plain", "lamps  on\tkitchen", []string{"lamps", "on", "kitchen"}, nil},
+		{"double quotes", `note add "pump 3 is noisy"`, []string{"note", "add", "pump 3 is noisy"}, nil},
+		{"single quotes", `note add 'say "hi"'`, []string{"note", "add", `say "hi"`}, nil},
+		{"escaped space", `a\ b c`, []string{"a b", "c"}, nil},
+		{"escaped quote", `it\'s`, []string{"it's"}, nil},
+		{"double quote escapes", `"a\"b\\c\d"`, []string{`a"b\c\d`}, nil},
+		{"single quote backslash", `'a\b'`, []string{`a\b`}, nil},
+		{"adjacent", `foo"bar baz"'qux'`, []string{"foobar bazqux"}, nil},
+		{"empty quotes", `a "" ''`, []string{"a", "", ""}, nil},
+		{"unicode", "écho «ça va»", []string{"écho", "«ça", "va»"}, nil},
+		{"unterminated double", `note "pump`, nil, ErrUnterminatedQuote},
+		{"unterminated single", `note 'pump`, nil, ErrUnterminatedQuote},
+		{"trailing escape", `note \`, nil, ErrTrailingEscape},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			words, err := Split(test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(words) == 0 && len(test.words) == 0 {
+				return
+			}
+			if !slices.Equal(words, test.words) {
+				t.Errorf("words = %q, want %q", words, test.words)
+			}
+		})
+	}
+}
+
+func TestLexPartial(t *testing.T) {
+	tokens, err := lex(`note add "pump 3`)
+
+	var serr *SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("err = %v, want *SyntaxError", err)
+	}
+	if serr.Offset != 9 {
+		t.Errorf("offset = %d, want %d", serr.Offset, 9)
+	}
+
+	want := []token{
+		{val: "note", start: 0, end: 4},
+		{val: "add", start: 5, end: 8},
+		{val: "pump 3", start: 9, end: 16},
+	}
+	if !slices.Equal(tokens, want) {
+		t.Errorf("tokens = %v, want %v", tokens, want)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Middleware wraps a command function, for example to log or time
+// commands.  The command being executed is available from CallFrom.
+type Middleware func(CmdFunc) CmdFunc
+
+// Call describes the command being executed.
+type Call struct {
+	Name    string   // Name is the resolved command execution string
+	Args    []string // Args are the arguments as entered
+	Command Command  // Command is the registered command
+}
+
+type callKey struct{}
+
+// CallFrom returns the command being executed by Exec as carried by ctx.
+func CallFrom(ctx context.Context) (Call, bool) {
+	c, ok := ctx.Value(callKey{}).(Call)
+	return c, ok
+}
+
+// scopedMiddleware is middleware applied to a group of commands.
+type scopedMiddleware struct {
+	group string
+	mw    Middleware
+}
+
+// Use adds middleware applied to every command.  Middleware runs in the order
+// it's added, so the first is outermost, and before any group middleware.
+// Command arguments are validated by the innermost function so middleware
+// sees usage errors too.
+func (sh *Shell) Use(mw ...Middleware) {
+	sh.UseGroup("", mw...)
+}
+
+// UseGroup adds middleware applied to the commands in a group, which is a
+// command execution string and all those beginning with it as a word, so
+// "lamps" includes "lamps on" and "lamps off".  Middleware for a group runs
+// after that of any groups containing it.
+func (sh *Shell) UseGroup(group string, mw ...Middleware) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	var scoped []scopedMiddleware
+	if p := sh.mw.Load(); p != nil {
+		scoped = slices.Clone(*p)
+	}
+	for _, m := range mw {
+		scoped = append(scoped, scopedMiddleware{group: group, mw: m})
+	}
+	slices.SortStableFunc(scoped, func(a, b scopedMiddleware) int {
+		return len(a.group) - len(b.group)
+	})
+
+	sh.mw.Store(&scoped)
+}
+
+// chain wraps f with the middleware applying to the command name.
+func (sh *Shell) chain(name string, f CmdFunc) CmdFunc {
+	p := sh.mw.Load()
+	if p == nil {
+		return f
+	}
+
+	scoped := *p
+	for i := len(scoped) - 1; i >= 0; i-- {
+		g := scoped[i].group
+		if g == "" || name == g || strings.HasPrefix(name, g+" ") {
+			f = scoped[i].mw(f)
+		}
+	}
+
+	return f
+}
+
+// call returns the function Exec runs for a resolved command: its arguments
+// are validated and it's run, wrapped with the middleware.  A command without
+// a Func, such as one registered only for its documentation, returns
+// ErrCmdNotImplemented.
+func (sh *Shell) call(m match) CmdFunc {
+	f := func(ctx context.Context, rw io.ReadWriter, args ...string) error {
+		if m.cmd.Func == nil {
+			return fmt.Errorf("%w %q", ErrCmdNotImplemented, m.name)
+		}
+		if m.cmd.declared() {
+			vals, parsed, err := m.cmd.parse(m.name, args)
+			if err != nil {
+				return err
+			}
+			ctx = context.WithValue(ctx, valuesKey{}, vals)
+			args = parsed
+		}
+
+		return m.cmd.Func(ctx, rw, args...)
+	}
+
+	return sh.chain(m.name, f)
+}
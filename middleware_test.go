@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var trace []string
+	mw := func(name string) Middleware {
+		return func(next CmdFunc) CmdFunc {
+			return func(ctx context.Context, rw io.ReadWriter, args ...string) error {
+				c, _ := CallFrom(ctx)
+				trace = append(trace, name+" "+c.Name+" "+strings.Join(c.Args, ","))
+				return next(ctx, rw, args...)
+			}
+		}
+	}
+
+	sh := testShell()
+	sh.RegisterCommand(Command{
+		Func: func(_ context.Context, _ io.ReadWriter, args ...string) error {
+			trace = append(trace, "func "+strings.Join(args, ","))
+			return nil
+		},
+		Args: []Arg{{Name: "zone", Required: true}, {Name: "level", Default: "100"}},
+	}, "lamps on")
+	sh.UseGroup("lamps on", mw("on"))
+	sh.UseGroup("lamps", mw("lamps"))
+	sh.Use(mw("global1"), mw("global2"))
+	sh.UseGroup("lamp", mw("partial"))
+
+	for _, test := range []struct {
+		input string
+		trace []string
+		err   error
+	}{
+		{"la on kitchen", []string{
+			"global1 lamps on kitchen",
+			"global2 lamps on kitchen",
+			"lamps lamps on kitchen",
+			"on lamps on kitchen",
+			"func kitchen,100",
+		}, nil},
+		{"la on", []string{
+			"global1 lamps on ",
+			"global2 lamps on ",
+			"lamps lamps on ",
+			"on lamps on ",
+		}, ErrUsage},
+		{"la of", []string{
+			"global1 lamps off ",
+			"global2 lamps off ",
+			"lamps lamps off ",
+		}, nil},
+		{"up", []string{
+			"global1 uptime ",
+			"global2 uptime ",
+		}, nil},
+		{"xyzzy", nil, ErrCmdNotFound},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			trace = nil
+			err := sh.Exec(context.Background(), nil, test.input)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Exec error = %v, want %v", err, test.err)
+			}
+			if !slices.Equal(trace, test.trace) {
+				t.Errorf("trace %q, want %q", trace, test.trace)
+			}
+		})
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+	sh := testShell()
+	sh.UseGroup("watch", func(CmdFunc) CmdFunc {
+		return func(context.Context, io.ReadWriter, ...string) error {
+			return errDenied
+		}
+	})
+
+	if err := sh.Exec(context.Background(), nil, "wa log d"); err != errDenied {
+		t.Errorf("Exec error = %v, want %v", err, errDenied)
+	}
+	if err := sh.Exec(context.Background(), nil, "whoami"); err != nil {
+		t.Errorf("Exec error = %v, want <nil>", err)
+	}
+}
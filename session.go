@@ -0,0 +1,593 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is the error of a command interrupted by Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// Session defaults.
+const (
+	DefaultPrompt      = "> "
+	DefaultHistorySize = 500
+)
+
+// Session is an interactive line editor which reads keystrokes from an
+// io.ReadWriter, such as a terminal in raw mode or a network connection,
+// and executes the entered lines with a Shell.
+//
+// Lines are edited with the arrow, Home, End, Backspace and Delete keys and
+// the readline style Ctrl-A, Ctrl-E, Ctrl-K, Ctrl-U and Ctrl-W.  Up and Down
+// navigate the History, Page Up and Page Down navigate only the entries
+// beginning with the text before the cursor, Ctrl-R and Ctrl-S incrementally
+// search it backward and forward, and Tab completes the word at the cursor,
+// listing the candidates if it is ambiguous.  Lines edited while navigating
+// the History are kept, as is the line being typed, until the next line is
+// entered.
+//
+// If the io.ReadWriter has a Width() int method it is used to learn the
+// terminal width, otherwise DefaultWidth is assumed.
+type Session struct {
+	Shell   *Shell
+	History *History
+	Prompt  string
+	ID      string // ID identifies the session in History entries
+
+	// PanicExit ends the session if a command panics, returning the
+	// *PanicError from Run.  Otherwise it's written to the session like
+	// other errors.
+	PanicExit bool
+
+	rw  io.ReadWriter
+	in  *interruptReader
+	kr  keyReader
+	out io.Writer
+	ctx context.Context // ctx is the context of Run, for completion
+
+	line       []rune // line is the line being edited
+	pos        int    // pos is the cursor position within line
+	navigating bool   // navigating is set while moving through History
+}
+
+// NewSession creates a new Session for executing commands with sh, reading
+// and writing rw.
+func NewSession(sh *Shell, rw io.ReadWriter) *Session {
+	in := &interruptReader{r: rw}
+	in.cond.L = &in.mu
+	return &Session{
+		Shell:   sh,
+		History: NewHistory(DefaultHistorySize),
+		Prompt:  DefaultPrompt,
+		rw:      rw,
+		in:      in,
+		kr:      keyReader{r: bufio.NewReader(in)},
+		out:     &crlfWriter{w: rw},
+	}
+}
+
+// Run reads and executes lines until the input ends, a command returns
+// ErrCmdQuit or ctx is done.  Errors returned by commands are written to the
+// session and, unless PanicExit is set and a command panics, do not end it.
+// History designators such as !! are expanded and each line is recorded in
+// the History along with when it ran, for how long and its error.  Commands
+// are executed, and completed, with ctx so it may carry the Principal running
+// them.
+//
+// Pressing Ctrl-C while a command runs cancels its context and, if it then
+// fails, ErrInterrupted is written in place of its error.  To see it the
+// session reads ahead while a command runs, so Run owns reading the
+// io.ReadWriter until it returns and, if it returns right after a command,
+// one read may still be in progress.
+func (s *Session) Run(ctx context.Context) error {
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+
+	for ctx.Err() == nil {
+		line, err := s.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Expand history designators, showing the result as bash
+		// does, and record the expanded line.
+		exp, err := s.History.Expand(line)
+		if err != nil {
+			fmt.Fprintf(s.out, "%v\n", err)
+			continue
+		}
+		if exp != line {
+			fmt.Fprintf(s.out, "%s\n", exp)
+			line = exp
+		}
+
+		start := time.Now()
+		recorded := s.History.AddEntry(HistoryEntry{Line: line, Time: start, Session: s.ID})
+		err = s.exec(ctx, line)
+		quit := errors.Is(err, ErrCmdQuit)
+		if quit {
+			err = nil
+		}
+		if recorded {
+			s.History.SetResult(time.Since(start), err)
+		}
+		if quit {
+			return nil
+		}
+		if s.PanicExit && errors.Is(err, ErrCmdPanic) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "%v\n", err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// exec executes a line, canceling the context passed to the command if
+// Ctrl-C is pressed while it runs.
+func (s *Session) exec(ctx context.Context, line string) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	s.in.interrupt(cancel)
+	defer s.in.interrupt(nil)
+
+	err := s.Shell.Exec(WithHistory(WithWidth(ctx, s.width()), s.History), struct {
+		io.Reader
+		io.Writer
+	}{s.kr.r, s.out}, line)
+	if err != nil && context.Cause(ctx) == ErrInterrupted {
+		err = ErrInterrupted
+	}
+
+	return err
+}
+
+// ReadLine writes the prompt and returns the next line as edited by the
+// user.  io.EOF is returned if the input ends or Ctrl-D is pressed on an
+// empty line.
+func (s *Session) ReadLine() (string, error) {
+	s.line, s.pos, s.navigating = s.line[:0], 0, false
+	s.History.Reset()
+	io.WriteString(s.out, s.Prompt)
+
+	for {
+		k, c, err := s.kr.readKey()
+		if k == keySearchBack || k == keySearchFwd {
+			k, c, err = s.search(k == keySearchBack)
+		}
+		if err != nil {
+			if err == io.EOF && len(s.line) > 0 {
+				io.WriteString(s.out, "\n")
+				return string(s.line), nil
+			}
+			return "", err
+		}
+
+		switch k {
+		case keyRune:
+			s.insert(c)
+		case keyEnter:
+			io.WriteString(s.out, "\n")
+			s.History.Reset()
+			return string(s.line), nil
+		case keyInterrupt:
+			io.WriteString(s.out, "^C\n")
+			s.line, s.pos, s.navigating = s.line[:0], 0, false
+			s.History.Reset()
+			s.History.dropPending()
+			io.WriteString(s.out, s.Prompt)
+		case keyEOF:
+			if len(s.line) == 0 {
+				io.WriteString(s.out, "\n")
+				return "", io.EOF
+			}
+			s.delete(s.pos, s.pos+1)
+		case keyBackspace:
+			s.delete(s.pos-1, s.pos)
+		case keyDelete:
+			s.delete(s.pos, s.pos+1)
+		case keyLeft:
+			s.move(s.pos - 1)
+		case keyRight:
+			s.move(s.pos + 1)
+		case keyHome:
+			s.move(0)
+		case keyEnd:
+			s.move(len(s.line))
+		case keyKillEnd:
+			s.delete(s.pos, len(s.line))
+		case keyKillStart:
+			s.delete(0, s.pos)
+		case keyKillWord:
+			i := s.pos
+			for i > 0 && unicode.IsSpace(s.line[i-1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(s.line[i-1]) {
+				i--
+			}
+			s.delete(i, s.pos)
+		case keyUp:
+			s.History.Edit(string(s.line))
+			if l := s.History.Prev(); s.History.Len() > 0 {
+				s.navigating = true
+				s.set(l)
+			}
+		case keyDown:
+			if s.navigating {
+				s.History.Edit(string(s.line))
+				s.set(s.History.Next())
+				s.navigating = !s.History.atEnd()
+			}
+		case keyPageUp:
+			pos := s.pos
+			s.History.Edit(string(s.line))
+			if l, ok := s.History.PrevPrefix(string(s.line[:pos])); ok {
+				s.navigating = true
+				s.set(l)
+				s.move(pos)
+			}
+		case keyPageDown:
+			if s.navigating {
+				pos := s.pos
+				prefix := string(s.line[:pos])
+				s.History.Edit(string(s.line))
+				l, ok := s.History.NextPrefix(prefix)
+				if s.navigating = ok; !ok {
+					l = prefix
+				}
+				s.set(l)
+				s.move(pos)
+			}
+		case keyTab:
+			s.complete()
+		case keyClear:
+			io.WriteString(s.out, "\x1b[H\x1b[2J")
+			s.refresh()
+		}
+	}
+}
+
+// search runs an incremental History search until a key other than a
+// search key is pressed.  The match becomes the line and the key is
+// returned so it can be processed as usual; Ctrl-C instead restores the
+// original line.  Repeating Ctrl-R or Ctrl-S finds the next older or newer
+// match.
+func (s *Session) search(back bool) (key, rune, error) {
+	orig := string(s.line)
+	s.History.Edit(orig)
+	query, match, failed := "", "", false
+
+	// Searches start from the newest or oldest entry.
+	start := func() int {
+		if back {
+			return s.History.Len()
+		}
+		return 0
+	}
+	idx := start()
+
+	find := func(from int) {
+		if query == "" {
+			return
+		}
+
+		var e string
+		var i int
+		if back {
+			e, i = s.History.SearchBack(query, from)
+		} else {
+			e, i = s.History.SearchForward(query, from)
+		}
+		if failed = i < 0; !failed {
+			match, idx = e, i
+		}
+	}
+
+	for {
+		prompt := "i-search"
+		if back {
+			prompt = "reverse-" + prompt
+		}
+		if failed {
+			prompt = "failed " + prompt
+		}
+		fmt.Fprintf(s.out, "\r(%s)`%s': %s\x1b[K", prompt, query, match)
+
+		k, c, err := s.kr.readKey()
+		if err != nil {
+			return k, c, err
+		}
+
+		switch k {
+		case keyRune:
+			query += string(c)
+			find(idx)
+		case keyBackspace:
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				match, idx = "", start()
+				find(idx)
+			}
+		case keySearchBack:
+			back = true
+			find(idx - 1)
+		case keySearchFwd:
+			back = false
+			find(idx + 1)
+		case keyInterrupt:
+			s.set(orig)
+			return keyNone, 0, nil
+		default:
+			if match == "" {
+				s.set(orig)
+				return k, c, nil
+			}
+
+			s.set(match)
+			s.History.Seek(idx)
+			s.navigating = true
+			return k, c, nil
+		}
+	}
+}
+
+// insert inserts a character at the cursor.
+func (s *Session) insert(c rune) {
+	s.line = append(s.line, 0)
+	copy(s.line[s.pos+1:], s.line[s.pos:])
+	s.line[s.pos] = c
+	s.pos++
+
+	if s.pos == len(s.line) {
+		io.WriteString(s.out, string(c))
+		return
+	}
+	s.refresh()
+}
+
+// delete removes the characters in [i, j), clamped to the line.
+func (s *Session) delete(i, j int) {
+	i, j = max(i, 0), min(j, len(s.line))
+	if i >= j {
+		return
+	}
+
+	s.line = append(s.line[:i], s.line[j:]...)
+	s.pos = i
+	s.refresh()
+}
+
+// move moves the cursor to i, clamped to the line.
+func (s *Session) move(i int) {
+	i = max(0, min(i, len(s.line)))
+	if i == s.pos {
+		return
+	}
+
+	if i < s.pos {
+		fmt.Fprintf(s.out, "\x1b[%dD", s.pos-i)
+	} else {
+		fmt.Fprintf(s.out, "\x1b[%dC", i-s.pos)
+	}
+	s.pos = i
+}
+
+// set replaces the line and moves the cursor to the end.
+func (s *Session) set(line string) {
+	s.line = append(s.line[:0], []rune(line)...)
+	s.pos = len(s.line)
+	s.refresh()
+}
+
+// refresh redraws the prompt and line and positions the cursor.
+func (s *Session) refresh() {
+	fmt.Fprintf(s.out, "\r%s%s\x1b[K", s.Prompt, string(s.line))
+	if n := len(s.line) - s.pos; n > 0 {
+		fmt.Fprintf(s.out, "\x1b[%dD", n)
+	}
+}
+
+// complete completes the word at the cursor.  The word is replaced by the
+// candidate if there is only one or extended to their common prefix.  If it
+// can't be extended the candidates are listed.
+func (s *Session) complete() {
+	line := string(s.line)
+	pos := len(string(s.line[:s.pos]))
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c := s.Shell.CompleteAtContext(ctx, line, pos)
+	if len(c.Candidates) == 0 {
+		io.WriteString(s.out, "\a")
+		return
+	}
+
+	values := make([]string, len(c.Candidates))
+	for i := range c.Candidates {
+		values[i] = c.Candidates[i].Value
+	}
+	repl := commonPrefix(values)
+	if c.Space {
+		repl += " "
+	}
+
+	if len(c.Candidates) == 1 || len(repl) > pos-c.Start {
+		head := line[:c.Start] + repl
+		s.line = append(s.line[:0], []rune(head+line[c.End:])...)
+		s.pos = utf8.RuneCountInString(head)
+		s.refresh()
+		return
+	}
+
+	io.WriteString(s.out, "\n")
+	s.list(c.Candidates)
+	s.refresh()
+}
+
+// list writes completion candidates in columns, or with their descriptions
+// if any have one.
+func (s *Session) list(candidates []Candidate) {
+	width := s.width()
+
+	values := make([]string, len(candidates))
+	descs := make([]string, len(candidates))
+	col, described := 0, false
+	for i, c := range candidates {
+		values[i], descs[i] = c.Value, c.Description
+		col = max(col, utf8.RuneCountInString(c.Value)+2)
+		described = described || c.Description != ""
+	}
+	if described {
+		helpList(s.out, width, values, descs)
+		return
+	}
+
+	perRow := max(1, width/col)
+	for i, v := range values {
+		if (i+1)%perRow == 0 || i == len(values)-1 {
+			fmt.Fprintf(s.out, "%s\n", v)
+		} else {
+			fmt.Fprintf(s.out, "%-*s", col, v)
+		}
+	}
+}
+
+// width returns the terminal width.
+func (s *Session) width() int {
+	if w, ok := s.rw.(interface{ Width() int }); ok && w.Width() > 0 {
+		return w.Width()
+	}
+
+	return DefaultWidth
+}
+
+// crlfWriter translates line feeds to carriage return and line feed pairs
+// as needed by terminals in raw mode and network virtual terminals.
+type crlfWriter struct {
+	w  io.Writer
+	cr bool
+}
+
+func (cw *crlfWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p)+8)
+	for _, b := range p {
+		if b == '\n' && !cw.cr {
+			buf = append(buf, '\r')
+		}
+		buf = append(buf, b)
+		cw.cr = b == '\r'
+	}
+
+	if _, err := cw.w.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// interruptReader reads from r so that Ctrl-C can be seen while a command
+// runs.  While one runs r is read in the background, where Ctrl-C cancels
+// the command instead of being read, and otherwise it's read directly.  The
+// background read in progress when the command ends is left to complete and
+// its data is returned by the next Read, so at most one read outlives Run.
+type interruptReader struct {
+	r io.Reader
+
+	mu       sync.Mutex
+	cond     sync.Cond
+	cancel   context.CancelCauseFunc // cancel interrupts the running command
+	watching bool                    // watching is set while r is read in the background
+	buf      []byte                  // buf is data read in the background
+	err      error                   // err is the error that ended reading
+}
+
+func (ir *interruptReader) Read(p []byte) (int, error) {
+	ir.mu.Lock()
+	for len(ir.buf) == 0 && ir.err == nil && ir.watching {
+		ir.cond.Wait()
+	}
+	if len(ir.buf) > 0 {
+		n := copy(p, ir.buf)
+		ir.buf = ir.buf[n:]
+		ir.mu.Unlock()
+		return n, nil
+	}
+	err := ir.err
+	ir.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return ir.r.Read(p)
+}
+
+// watch reads r in the background until the running command ends or there
+// is an error.
+func (ir *interruptReader) watch() {
+	for {
+		buf := make([]byte, 512)
+		n, err := ir.r.Read(buf)
+
+		ir.mu.Lock()
+		ir.buf = append(ir.buf, ir.filter(buf[:n])...)
+		ir.err = err
+		ir.watching = err == nil && ir.cancel != nil
+		watching := ir.watching
+		ir.cond.Broadcast()
+		ir.mu.Unlock()
+
+		if !watching {
+			return
+		}
+	}
+}
+
+// interrupt sets the function which cancels the running command, or nil if
+// none is running, starting the background read if needed.
+func (ir *interruptReader) interrupt(cancel context.CancelCauseFunc) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	ir.cancel = cancel
+	if cancel != nil && !ir.watching && ir.err == nil {
+		ir.watching = true
+		go ir.watch()
+	}
+}
+
+// filter removes Ctrl-C from buf, canceling the running command, if there is
+// one.  The caller must hold mu.
+func (ir *interruptReader) filter(buf []byte) []byte {
+	if ir.cancel == nil || !bytes.Contains(buf, []byte{0x03}) {
+		return buf
+	}
+
+	ir.cancel(ErrInterrupted)
+	return bytes.ReplaceAll(buf, []byte{0x03}, nil)
+}
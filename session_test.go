@@ -0,0 +1,318 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTerm is a terminal with scripted input.
+type testTerm struct {
+	in io.Reader
+	bytes.Buffer
+}
+
+func (t *testTerm) Read(p []byte) (int, error) { return t.in.Read(p) }
+
+// runSession runs a session over the input and returns the executed command
+// lines and the session.
+func runSession(t *testing.T, input string) ([]string, *Session, *testTerm) {
+	t.Helper()
+
+	var sh Shell
+	var ran []string
+	for cmd := range testShell().cmds().Match("") {
+		sh.Register(func(_ context.Context, _ io.ReadWriter, args ...string) error {
+			ran = append(ran, strings.Join(append([]string{cmd}, args...), " "))
+			return nil
+		}, cmd)
+	}
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return ErrCmdQuit }, "quit")
+	sh.RegisterCommand(Command{
+		Func:    func(context.Context, io.ReadWriter, ...string) error { return nil },
+		Summary: "Show help",
+	}, "help")
+
+	term := &testTerm{in: strings.NewReader(input)}
+	s := NewSession(&sh, term)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	return ran, s, term
+}
+
+func TestSessionEditing(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		ran   []string
+	}{
+		{"enter", "uptime\r", []string{"uptime"}},
+		{"crlf", "uptime\r\ndate\r\x00", []string{"uptime", "date"}},
+		{"lf", "uptime\n", []string{"uptime"}},
+		{"eof", "uptime", []string{"uptime"}},
+		{"backspace", "uptimex\x7f\r", []string{"uptime"}},
+		{"ctrl-h", "uptimex\x08\r", []string{"uptime"}},
+		{"arrows", "uptme\x1b[D\x1b[Di\x1b[C\x1b[C\r", []string{"uptime"}},
+		{"ss3 arrows", "uptme\x1bOD\x1bODi\r", []string{"uptime"}},
+		{"home end", "ptim\x1b[Hu\x1b[Fe\r", []string{"uptime"}},
+		{"vt220 home end", "ptim\x1b[1~u\x1b[4~e\r", []string{"uptime"}},
+		{"ctrl-a ctrl-e", "ptim\x01u\x05e\r", []string{"uptime"}},
+		{"delete", "xuptime\x01\x1b[3~\r", []string{"uptime"}},
+		{"ctrl-d deletes", "xuptime\x01\x04\r", []string{"uptime"}},
+		{"ctrl-k", "uptime now\x1b[D\x1b[D\x1b[D\x1b[D\x0b\r", []string{"uptime"}},
+		{"ctrl-u", "junk uptime\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x15\r", []string{"uptime"}},
+		{"ctrl-w", "uptime junk words \x17\x17\r", []string{"uptime"}},
+		{"ctrl-c", "junk\x03uptime\r", []string{"uptime"}},
+		{"ctrl-d quits", "\x04uptime\r", nil},
+		{"quit", "quit\ruptime\r", nil},
+		{"utf-8", "date «ça»\x1b[D\x7f\r", []string{"date «ç»"}},
+		{"blank", "  \r\r", nil},
+		{"error continues", "xyz\ruptime\r", []string{"uptime"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ran, _, _ := runSession(t, test.input)
+			if !slices.Equal(ran, test.ran) {
+				t.Errorf("ran %q, want %q", ran, test.ran)
+			}
+		})
+	}
+}
+
+func TestSessionHistory(t *testing.T) {
+	ran, s, _ := runSession(t, "uptime\rdate\r\x1b[A\x1b[A\r\x10\x10\x10\x0e\r\x1b[Bversion\r")
+	if want := []string{"uptime", "date", "uptime", "date", "version"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+
+	if got := s.History.Prev(); got != "version" {
+		t.Errorf("last history entry = %q, want %q", got, "version")
+	}
+}
+
+func TestSessionHistoryEdit(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		ran   []string
+	}{
+		{"pending", "ver\x1b[A\x1b[Bsion\r", []string{"version"}},
+		{"edited", "\x1b[A\x7f\x7f\x7f\x7fuptime\x1b[A\x1b[B\r", []string{"uptime"}},
+		{"edited pending", "la\x1b[A\x1b[A\x1b[B\x17version\x1b[B\x1b[A\r", []string{"version"}},
+		{"interrupted", "\x1b[A\x1b[A\x03\x1b[B\x1b[A\x1b[A\r", []string{"uptime"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ran, _, _ := runSession(t, "uptime\rdate\r"+test.input)
+			if !slices.Equal(ran[2:], test.ran) {
+				t.Errorf("ran %q, want %q", ran[2:], test.ran)
+			}
+		})
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		ran   []string
+		out   string
+	}{
+		{"unique", "upt\t\r", []string{"uptime"}, ""},
+		{"multi-word", "wa\tlog\tt\t\r", []string{"watch log trace"}, ""},
+		{"middle", "wa lo d\x1b[D\x1b[D\t\r", nil, "log    loops\r\n"},
+		{"list", "lo\t\r", nil, "logout  loop\r\n"},
+		{"list described", "he\t\r", nil, "  health\r\n  help    Show help\r\n"},
+		{"no match", "xyz\t\r", nil, "\a"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ran, _, term := runSession(t, test.input)
+			if !slices.Equal(ran, test.ran) {
+				t.Errorf("ran %q, want %q", ran, test.ran)
+			}
+			if !strings.Contains(term.String(), test.out) {
+				t.Errorf("output %q does not contain %q", term.String(), test.out)
+			}
+		})
+	}
+}
+
+func TestCRLFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &crlfWriter{w: &buf}
+	io.WriteString(w, "a\nb\r\nc\r")
+	io.WriteString(w, "\nd\n")
+
+	if want := "a\r\nb\r\nc\r\nd\r\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSessionSearch(t *testing.T) {
+	history := "watch log debug\rdate\rwatch loops\rlamps on\r"
+	for _, test := range []struct {
+		name  string
+		input string
+		ran   []string
+	}{
+		{"reverse", "\x12wat\r", []string{"watch loops"}},
+		{"repeat", "\x12wat\x12\r", []string{"watch log debug"}},
+		{"forward", "\x12wat\x12\x13\r", []string{"watch loops"}},
+		{"backspace", "\x12datx\x7f\r", []string{"date"}},
+		{"edit match", "\x12dat\x1b[C 1\r", []string{"date 1"}},
+		{"navigate from match", "\x12loops\x1b[A\r", []string{"date"}},
+		{"cancel", "lamps\x12wat\x03 on\r", []string{"lamps on"}},
+		{"failed", "\x12xyz\r", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ran, _, _ := runSession(t, history+test.input)
+			if len(ran) < 4 {
+				t.Fatalf("ran %q", ran)
+			}
+			if !slices.Equal(ran[4:], test.ran) {
+				t.Errorf("ran %q, want %q", ran[4:], test.ran)
+			}
+		})
+	}
+}
+
+func TestSessionPrefixHistory(t *testing.T) {
+	history := "watch log debug\rdate\rwatch loops\rlamps on\r"
+	for _, test := range []struct {
+		name  string
+		input string
+		ran   []string
+	}{
+		{"prev", "wa\x1b[5~\r", []string{"watch loops"}},
+		{"prev twice", "wa\x1b[5~\x1b[5~\r", []string{"watch log debug"}},
+		{"next", "wa\x1b[5~\x1b[5~\x1b[6~\r", []string{"watch loops"}},
+		{"restore prefix", "wa\x1b[5~\x1b[6~ c\r", []string{"watch conditions"}},
+		{"cursor kept", "la\x1b[5~\x0bmps off\r", []string{"lamps off"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ran, _, _ := runSession(t, history+test.input)
+			if !slices.Equal(ran[4:], test.ran) {
+				t.Errorf("ran %q, want %q", ran[4:], test.ran)
+			}
+		})
+	}
+}
+
+func TestSessionExpand(t *testing.T) {
+	ran, s, term := runSession(t, "watch log debug\r^debug^trace\r!!\r!xyz\r!wa\r")
+	if want := []string{"watch log debug", "watch log trace", "watch log trace", "watch log trace"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+	if !strings.Contains(term.String(), "event not found: !xyz\r\n") {
+		t.Errorf("output %q does not report event not found", term.String())
+	}
+
+	var lines []string
+	for _, e := range s.History.Entries() {
+		lines = append(lines, e.Line)
+	}
+	if want := []string{"watch log debug", "watch log trace"}; !slices.Equal(lines, want) {
+		t.Errorf("history %q, want %q", lines, want)
+	}
+}
+
+func TestSessionPanic(t *testing.T) {
+	var sh Shell
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { panic("oops") }, "crash")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return nil }, "uptime")
+
+	for _, exit := range []bool{false, true} {
+		term := &testTerm{in: strings.NewReader("crash\ruptime\r")}
+		s := NewSession(&sh, term)
+		s.PanicExit = exit
+		err := s.Run(context.Background())
+		if got := errors.Is(err, ErrCmdPanic); got != exit {
+			t.Errorf("PanicExit %t: Run error = %v", exit, err)
+		}
+		if got := strings.Contains(term.String(), `command panicked "crash": oops`); got == exit {
+			t.Errorf("PanicExit %t: output %q", exit, term.String())
+		}
+		want := 2
+		if exit {
+			want = 1
+		}
+		if got := s.History.Len(); got != want {
+			t.Errorf("PanicExit %t: %d history entries, want %d", exit, got, want)
+		}
+	}
+}
+
+func TestSessionInterrupt(t *testing.T) {
+	started := make(chan struct{})
+	var sh Shell
+	sh.Register(func(ctx context.Context, _ io.ReadWriter, _ ...string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, "trend")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return nil }, "uptime")
+
+	pr, pw := io.Pipe()
+	term := &testTerm{in: pr}
+	s := NewSession(&sh, term)
+	done := make(chan error)
+	go func() { done <- s.Run(context.Background()) }()
+
+	io.WriteString(pw, "trend\r")
+	<-started
+	io.WriteString(pw, "\x03uptime\r")
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	if !strings.Contains(term.String(), "trend\r\ninterrupted\r\n> uptime") {
+		t.Errorf("output %q does not show the interrupt", term.String())
+	}
+	var errs []error
+	for _, e := range s.History.Entries() {
+		errs = append(errs, e.Err)
+	}
+	if want := []error{ErrInterrupted, nil}; !slices.Equal(errs, want) {
+		t.Errorf("history errors %v, want %v", errs, want)
+	}
+}
+
+func TestSessionReadAhead(t *testing.T) {
+	var sh Shell
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return ErrCmdQuit }, "quit")
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	s := NewSession(&sh, &testTerm{in: pr})
+	done := make(chan error)
+	go func() { done <- s.Run(context.Background()) }()
+
+	io.WriteString(pw, "quit\r")
+	if err := <-done; err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	// The read in progress when quit returned completes, but the session
+	// reads no further once Run has returned.
+	io.WriteString(pw, "a")
+	written := make(chan bool)
+	go func() {
+		_, err := io.WriteString(pw, "b")
+		written <- err == nil
+	}()
+	select {
+	case <-written:
+		t.Error("input read after Run returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
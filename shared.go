@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"sync"
+	"time"
+)
+
+// SharedHistory is a history shared by several sessions, such
+// as all of a user's telnet sessions.  It's safe for concurrent
+// use and each session navigates it with its own History.
+type SharedHistory struct {
+	mu   sync.Mutex
+	h    *History
+	live bool
+	opts []HistoryOption
+}
+
+// NewSharedHistory creates a new SharedHistory.  max and opts
+// are as for NewHistory and also apply to each session's
+// History, except that only the SharedHistory uses the store.
+//
+// Each History starts with the entries shared so far.  If live
+// is true, like zsh's SHARE_HISTORY, it also picks up entries
+// added by other sessions whenever its cursor is reset to the
+// end; otherwise it only adds its own.
+func NewSharedHistory(max int, live bool, opts ...HistoryOption) *SharedHistory {
+	return &SharedHistory{
+		h:    NewHistory(max, opts...),
+		live: live,
+		opts: opts,
+	}
+}
+
+// History returns a new History for a session.  Its entries are
+// added to the SharedHistory.  The History itself is not safe
+// for concurrent use.
+func (sh *SharedHistory) History() *History {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	h := &History{max: sh.h.max}
+	for _, opt := range sh.opts {
+		opt(h)
+	}
+	h.store = nil
+	h.shared = sh
+
+	h.sync()
+	h.cursor = len(h.entries)
+
+	return h
+}
+
+// Entries returns a copy of the shared entries, oldest first.
+func (sh *SharedHistory) Entries() []HistoryEntry {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	return append([]HistoryEntry(nil), sh.h.entries...)
+}
+
+// Err returns the first error encountered loading from or
+// appending to the store.
+func (sh *SharedHistory) Err() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	return sh.h.err
+}
+
+// add adds an entry from h, first syncing h if the history is
+// live so its entries stay in order.
+func (sh *SharedHistory) add(h *History, e HistoryEntry) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.live {
+		h.sync()
+	}
+	h.sharedNum = 0
+	if sh.h.AddEntry(e) {
+		h.sharedNum = sh.h.entries[len(sh.h.entries)-1].Num
+	}
+	if n := len(sh.h.entries); sh.live && n > 0 {
+		h.sharedSeen = sh.h.entries[n-1].Num
+	}
+}
+
+// setResult records the duration and error of entry num.
+func (sh *SharedHistory) setResult(num int, d time.Duration, err error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	for i := len(sh.h.entries) - 1; i >= 0; i-- {
+		if sh.h.entries[i].Num == num {
+			sh.h.entries[i].Duration, sh.h.entries[i].Err = d, err
+			return
+		}
+	}
+}
+
+// sync adds the shared entries h hasn't seen.  The caller must
+// hold the lock.
+func (h *History) sync() {
+	sh := h.shared.h
+	for _, e := range sh.entries {
+		if e.Num > h.sharedSeen {
+			h.add(e)
+		}
+	}
+	if n := len(sh.entries); n > 0 {
+		h.sharedSeen = sh.entries[n-1].Num
+	}
+}
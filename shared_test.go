@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func lines(h *History) []string {
+	var l []string
+	for _, e := range h.Entries() {
+		l = append(l, e.Line)
+	}
+
+	return l
+}
+
+func TestSharedHistory(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		live bool
+		a, b []string
+	}{
+		{"private", false, []string{"uptime", "date"}, []string{"uptime", "version"}},
+		{"live", true, []string{"uptime", "date", "version"}, []string{"uptime", "date", "version"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sh := NewSharedHistory(0, test.live)
+			a := sh.History()
+			a.Add("uptime")
+			b := sh.History()
+			a.Add("date")
+			b.Add("version")
+			a.Reset()
+			b.Reset()
+
+			if got := lines(a); !slices.Equal(got, test.a) {
+				t.Errorf("a entries %q, want %q", got, test.a)
+			}
+			if got := lines(b); !slices.Equal(got, test.b) {
+				t.Errorf("b entries %q, want %q", got, test.b)
+			}
+
+			var shared []string
+			for _, e := range sh.Entries() {
+				shared = append(shared, e.Line)
+			}
+			if want := []string{"uptime", "date", "version"}; !slices.Equal(shared, want) {
+				t.Errorf("shared entries %q, want %q", shared, want)
+			}
+
+			// Each History has its own cursor.
+			a.Prev()
+			if got, want := a.Prev(), test.a[len(test.a)-2]; got != want {
+				t.Errorf("a Prev() = %q, want %q", got, want)
+			}
+			if got, want := b.Prev(), test.b[len(test.b)-1]; got != want {
+				t.Errorf("b Prev() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSharedHistoryResult(t *testing.T) {
+	sh := NewSharedHistory(0, false)
+	a, b := sh.History(), sh.History()
+
+	errTest := errors.New("test")
+	a.AddEntry(HistoryEntry{Line: "trend", Time: time.Now(), Session: "a"})
+	b.AddEntry(HistoryEntry{Line: "date", Time: time.Now(), Session: "b"})
+	a.SetResult(time.Second, errTest)
+
+	e := sh.Entries()[0]
+	if e.Line != "trend" || e.Duration != time.Second || e.Err != errTest || e.Session != "a" {
+		t.Errorf("entry %+v, want trend's result", e)
+	}
+	if e := sh.Entries()[1]; e.Duration != 0 || e.Err != nil {
+		t.Errorf("entry %+v, want no result", e)
+	}
+}
+
+func TestSharedHistoryStore(t *testing.T) {
+	fst := FileStore{Path: filepath.Join(t.TempDir(), "history")}
+	sh := NewSharedHistory(0, true, WithStore(fst))
+	sh.History().Add("uptime")
+	sh.History().Add("date")
+
+	entries, err := fst.Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if want := []string{"uptime", "date"}; !slices.Equal(entries, want) {
+		t.Errorf("stored %q, want %q", entries, want)
+	}
+
+	if got, want := lines(NewSharedHistory(0, true, WithStore(fst)).History()), entries; !slices.Equal(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+func TestSharedHistoryConcurrent(t *testing.T) {
+	const sessions, adds = 8, 50
+
+	sh := NewSharedHistory(0, true)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Go(func() {
+			h := sh.History()
+			for j := range adds {
+				h.AddEntry(HistoryEntry{Line: fmt.Sprintf("cmd %d %d", i, j)})
+				h.SetResult(time.Millisecond, nil)
+				h.Reset()
+				h.Prev()
+			}
+		})
+	}
+	wg.Wait()
+
+	entries := sh.Entries()
+	if len(entries) != sessions*adds {
+		t.Fatalf("%d entries, want %d", len(entries), sessions*adds)
+	}
+	for i, e := range entries {
+		if e.Num != i+1 {
+			t.Errorf("entry %d Num = %d, want %d", i, e.Num, i+1)
+		}
+	}
+	if got := lines(sh.History()); len(got) != sessions*adds {
+		t.Errorf("History has %d entries, want %d", len(got), sessions*adds)
+	}
+}
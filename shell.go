@@ -11,49 +11,257 @@ import (
 	"errors"
 	"io"
 	"iter"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ebarkie/textcmd/internal/trie"
 )
 
 // Errors.
 var (
-	ErrCmdNotFound = errors.New("command not found")
-	ErrCmdQuit     = errors.New("quit command")
+	ErrAmbiguousCmd      = errors.New("ambiguous command")
+	ErrCmdNotFound       = errors.New("command not found")
+	ErrCmdNotImplemented = errors.New("command not implemented")
+	ErrCmdPanic          = errors.New("command panicked")
+	ErrCmdQuit           = errors.New("quit command")
+	ErrCmdTimeout        = errors.New("command timed out")
+	ErrPermission        = errors.New("permission denied")
 )
 
 // CmdFunc is the function signature for command handlers.
 type CmdFunc func(context.Context, io.ReadWriter, ...string) error
 
 // Shell is a text command shell for which commands can be
-// registered and executed.
+// registered and executed.  It's safe for concurrent use and
+// commands may be registered while others are executing.
 type Shell struct {
-	cmds trie.Node
+	mu    sync.Mutex                         // mu serializes registration
+	root  atomic.Pointer[trie.Node]          // root is the current command trie
+	mw    atomic.Pointer[[]scopedMiddleware] // mw is the current middleware
+	auth  atomic.Pointer[Authorizer]         // auth is the Authorizer, if any
+	audit atomic.Pointer[auditor]            // audit is the auditing, if any
+	views atomic.Pointer[views]              // views caches principals' views
 }
 
-// Exec attempts to execute the passed string as a command.
-func (sh Shell) Exec(ctx context.Context, rw io.ReadWriter, s string) error {
-	tokens := strings.Fields(s)
-	for i := range tokens {
-		cmd := strings.Join(tokens[:i+1], " ")
+// cmds returns the current command trie.  The trie is never
+// modified once stored, registration stores a modified copy
+// instead, so it may be read without locking.
+func (sh *Shell) cmds() *trie.Node {
+	if root := sh.root.Load(); root != nil {
+		return root
+	}
+
+	return &trie.Node{}
+}
+
+// Exec attempts to execute the passed string as a command.  The string is
+// split into words as described by Split and any words following the command
+// are passed to the command function as arguments.
+//
+// Each command word may be abbreviated to any unique prefix, so "wa log d"
+// runs "watch log debug".  If no command matches a *CmdNotFoundError is
+// returned and if more than one does an *AmbiguousCmdError is returned.
+//
+// If the command declares arguments or flags they are validated, returning a
+// *UsageError if they are invalid, and the command function is passed the
+// positional arguments with defaults filled in.
+//
+// The command function is wrapped with any middleware added by Use and
+// UseGroup.  If it panics the panic is recovered and a *PanicError is
+// returned.  If the command has a Timeout its context is canceled once it
+// elapses and, if it then fails, a *TimeoutError is returned.
+//
+// If the shell has an Authorizer commands are resolved among those the
+// principal carried by ctx is authorized to run.  If the input instead names
+// a single command it's not authorized to run a *PermissionError is
+// returned.  The execution is recorded to the shell's AuditSink, if any.
+func (sh *Shell) Exec(ctx context.Context, rw io.ReadWriter, s string) error {
+	tokens, err := Split(s)
+	if err != nil {
+		return err
+	}
+
+	// Resolve against only the commands the principal may run so hidden
+	// commands neither make abbreviations ambiguous nor appear in errors.
+	// Input naming a single hidden command is denied.
+	v := sh.view(ctx)
+	matches := v.resolve(tokens)
+	if len(matches) == 0 && v != sh {
+		if all := sh.resolve(tokens); len(all) == 1 {
+			matches = all
+		}
+	}
+	if len(matches) == 1 {
+		m := matches[0]
+		args := tokens[m.n:]
+		start := time.Now()
+		err := sh.authorize(ctx, m.name, *m.cmd)
+		if err == nil {
+			ctx := context.WithValue(ctx, callKey{}, Call{Name: m.name, Args: args, Command: *m.cmd})
+			err = sh.run(ctx, rw, m, args)
+		}
+		sh.record(ctx, m, args, start, err)
+
+		return err
+	}
+
+	input := strings.TrimSpace(s)
+	prefix, _ := v.cmds().Find(strings.Join(tokens, " "), ' ')
+	if len(matches) == 0 {
+		return &CmdNotFoundError{
+			Input:       input,
+			Prefix:      prefix,
+			Suggestions: v.suggest(tokens),
+		}
+	}
+
+	candidates := make([]string, len(matches))
+	for i := range matches {
+		candidates[i] = matches[i].name
+	}
+
+	return &AmbiguousCmdError{Input: input, Prefix: prefix, Candidates: candidates}
+}
+
+// run runs a resolved command, recovering any panic and applying its
+// Timeout.
+func (sh *Shell) run(ctx context.Context, rw io.ReadWriter, m match, args []string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Command: m.name, Args: args, Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	if m.cmd.Timeout <= 0 {
+		return sh.call(m)(ctx, rw, args...)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, m.cmd.Timeout, &TimeoutError{Command: m.name, Timeout: m.cmd.Timeout})
+	defer cancel()
+
+	err = sh.call(m)(ctx, rw, args...)
+	if te, ok := context.Cause(ctx).(*TimeoutError); ok && err != nil {
+		err = te
+	}
+
+	return
+}
+
+// match is a registered command that a command line may refer to.
+type match struct {
+	name string   // Full command name
+	n    int      // Number of words of the command line consumed
+	cmd  *Command // Command
+}
+
+// resolve returns the registered commands that the leading tokens may
+// abbreviate.  Each token is matched as a prefix of the command word in the
+// same position, with an exact word taking precedence over abbreviations of
+// longer words.  The longest command matched is returned, so the remaining
+// tokens are treated as arguments, unless a token abbreviates more than one
+// word.  Then every command beginning with those words is returned.
+func (sh *Shell) resolve(tokens []string) (matches []match) {
+	n, name := sh.cmds(), ""
+	for i, tok := range tokens {
+		if tok == "" {
+			break
+		}
+
+		var words []string
+		var nodes []*trie.Node
+		for w, cur := range n.Words(tok, ' ') {
+			words, nodes = append(words, w), append(nodes, cur)
+
+			// An exact word is always first and excludes abbreviations.
+			if w == tok {
+				break
+			}
+		}
+
+		if len(words) == 0 {
+			return
+		}
+		if len(words) > 1 {
+			matches = matches[:0]
+			for j, cur := range nodes {
+				matches = appendCmds(matches, cur, name+words[j], i+1)
+			}
+			return
+		}
+
+		if cur := nodes[0]; cur.Val != nil {
+			matches = append(matches[:0], match{name: name + words[0], n: i + 1, cmd: cur.Val.(*Command)})
+		}
+		if n = nodes[0].Get(" "); n == nil {
+			break
+		}
+		name += words[0] + " "
+	}
+
+	return
+}
 
-		if _, cur := sh.cmds.Find(cmd, ' '); cur != nil && cur.Val != nil {
-			return cur.Val.(CmdFunc)(ctx, rw, tokens[i+1:]...)
+// appendCmds appends the command ending at the node of the word name, if
+// any, and those of the words following it.
+func appendCmds(matches []match, n *trie.Node, name string, i int) []match {
+	if n.Val != nil {
+		matches = append(matches, match{name: name, n: i, cmd: n.Val.(*Command)})
+	}
+	if next := n.Get(" "); next != nil {
+		for sub := range next.Match("") {
+			matches = append(matches, match{name: name + " " + sub, n: i, cmd: next.Get(sub).Val.(*Command)})
 		}
 	}
 
-	return ErrCmdNotFound
+	return matches
 }
 
 // Complete returns the input expanded as far as possible and all possible full
-// command strings.
-func (sh Shell) Complete(s string) (completion string, matches iter.Seq[string]) {
-	completion, _ = sh.cmds.Find(s, ' ')
+// command strings.  If the input ends with an argument of a command that has
+// a completer, the argument is completed instead and the possible full
+// strings are the input with each candidate value.
+func (sh *Shell) Complete(s string) (completion string, matches iter.Seq[string]) {
+	return sh.CompleteContext(context.Background(), s)
+}
+
+// CompleteContext is like Complete but only completes the commands the
+// principal carried by ctx is authorized to run.
+func (sh *Shell) CompleteContext(ctx context.Context, s string) (completion string, matches iter.Seq[string]) {
+	return sh.view(ctx).complete(s)
+}
+
+// complete implements Complete.
+func (sh *Shell) complete(s string) (completion string, matches iter.Seq[string]) {
+	if start, candidates, ok := sh.completeArg(s); ok {
+		completion = s
+		if p := commonPrefix(candidates); len(candidates) > 0 {
+			completion = s[:start] + quote(p)
+			if p == "" {
+				completion = s[:start]
+			}
+		}
+
+		matches = func(yield func(string) bool) {
+			for _, c := range candidates {
+				if !yield(s[:start] + quote(c)) {
+					return
+				}
+			}
+		}
+
+		return
+	}
+
+	cmds := sh.cmds()
+	completion, _ = cmds.Find(completionKey(s), ' ')
 	if completion == "" {
 		completion = s
 	}
 
-	matches = sh.cmds.Match(completion)
+	matches = cmds.Match(completion)
 
 	return
 }
@@ -61,7 +269,23 @@ func (sh Shell) Complete(s string) (completion string, matches iter.Seq[string])
 // Register adds a command to the text command shell.  It takes a
 // command function and command execution strings.
 func (sh *Shell) Register(f CmdFunc, cmd ...string) {
-	for _, c := range cmd {
-		sh.cmds.Add(c, f)
+	sh.RegisterCommand(Command{Func: f}, cmd...)
+}
+
+// completionKey normalizes the words of s into a key suitable for searching
+// the command trie.  Quoting is removed and a trailing separator is kept so
+// "watch " still completes the next word.  Unterminated quotes are tolerated
+// since the user may still be typing.
+func completionKey(s string) string {
+	tokens, err := lex(s)
+	if len(tokens) == 0 {
+		return ""
 	}
+
+	key := strings.Join(words(tokens), " ")
+	if err == nil && tokens[len(tokens)-1].end < len(s) {
+		key += " "
+	}
+
+	return key
 }
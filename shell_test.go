@@ -6,13 +6,16 @@ package textcmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"slices"
+	"sync"
 	"testing"
 )
 
-func testShell() Shell {
-	var sh Shell
+func testShell() *Shell {
+	sh := &Shell{}
 	for _, cmd := range []string{
 		"?",
 		"archive",
@@ -70,6 +73,18 @@ func TestComplete(t *testing.T) {
 			completion: "watch lo",
 			matches:    []string{"watch log debug", "watch log trace", "watch loops"},
 		},
+		{
+			name:       "quoted",
+			input:      `"wa"  'l'`,
+			completion: "watch lo",
+			matches:    []string{"watch log debug", "watch log trace", "watch loops"},
+		},
+		{
+			name:       "trailing space",
+			input:      "lamps ",
+			completion: "lamps o",
+			matches:    []string{"lamps off", "lamps on"},
+		},
 		{
 			name:       "no match",
 			input:      "xyz",
@@ -123,3 +138,126 @@ func TestComplete(t *testing.T) {
 		})
 	}
 }
+
+func TestExecArgs(t *testing.T) {
+	sh := testShell()
+
+	var got []string
+	sh.Register(func(_ context.Context, _ io.ReadWriter, args ...string) error {
+		got = args
+		return nil
+	}, "note add")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return nil }, "note list")
+
+	if err := sh.Exec(context.Background(), nil, `note add "pump 3 is noisy" it\'s`); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	if want := []string{"pump 3 is noisy", "it's"}; !slices.Equal(got, want) {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+
+	if err := sh.Exec(context.Background(), nil, `note add "pump`); !errors.Is(err, ErrUnterminatedQuote) {
+		t.Errorf("Exec error = %v, want %v", err, ErrUnterminatedQuote)
+	}
+}
+
+func TestExecNoFunc(t *testing.T) {
+	sh := testShell()
+	sh.RegisterCommand(Command{Summary: "Show the date"}, "date")
+
+	err := sh.Exec(context.Background(), nil, "date")
+	if !errors.Is(err, ErrCmdNotImplemented) || errors.Is(err, ErrCmdPanic) {
+		t.Errorf("Exec error = %v, want %v", err, ErrCmdNotImplemented)
+	}
+}
+
+func TestExecAbbrev(t *testing.T) {
+	var sh Shell
+	var ran string
+	var args []string
+	for cmd := range testShell().cmds().Match("") {
+		sh.Register(func(_ context.Context, _ io.ReadWriter, a ...string) error {
+			ran, args = cmd, a
+			return nil
+		}, cmd)
+	}
+	sh.Register(func(_ context.Context, _ io.ReadWriter, a ...string) error {
+		ran, args = "watch log", a
+		return nil
+	}, "watch log")
+
+	for _, test := range []struct {
+		input string
+		cmd   string
+		args  []string
+		err   error
+	}{
+		{input: "uptime", cmd: "uptime"},
+		{input: "upt", cmd: "uptime"},
+		{input: "  upt  ", cmd: "uptime"},
+		{input: "loop", cmd: "loop"},
+		{input: "wa log d", cmd: "watch log debug"},
+		{input: "wa log t extra", cmd: "watch log trace", args: []string{"extra"}},
+		{input: "wa log", cmd: "watch log"},
+		{input: "wa log x", cmd: "watch log", args: []string{"x"}},
+		{input: "wa loo", cmd: "watch loops"},
+		{input: "la on", cmd: "lamps on"},
+		{input: "d 1 2", cmd: "date", args: []string{"1", "2"}},
+		{input: "lo", err: ErrAmbiguousCmd},
+		{input: "la o", err: ErrAmbiguousCmd},
+		{input: "wa lo", err: ErrAmbiguousCmd},
+		{input: "wa lo d", err: ErrAmbiguousCmd},
+		{input: "la", err: ErrCmdNotFound},
+		{input: "xyz", err: ErrCmdNotFound},
+		{input: "", err: ErrCmdNotFound},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			ran, args = "", nil
+			err := sh.Exec(context.Background(), nil, test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if ran != test.cmd {
+				t.Errorf("ran %q, want %q", ran, test.cmd)
+			}
+			if len(args) > 0 || len(test.args) > 0 {
+				if !slices.Equal(args, test.args) {
+					t.Errorf("args = %q, want %q", args, test.args)
+				}
+			}
+		})
+	}
+}
+
+func TestShellConcurrent(t *testing.T) {
+	sh := testShell()
+	noop := func(context.Context, io.ReadWriter, ...string) error { return nil }
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			for j := range 100 {
+				sh.Register(noop, fmt.Sprintf("plugin%d cmd%d", i, j))
+			}
+		})
+		wg.Go(func() {
+			for range 100 {
+				if err := sh.Exec(context.Background(), nil, "wa log d"); err != nil {
+					t.Errorf("Exec error: %v", err)
+				}
+				sh.Complete("wa lo")
+				sh.CompleteAt("lamps o", 7)
+				sh.Exec(context.Background(), nil, "plugin")
+			}
+		})
+	}
+	wg.Wait()
+
+	n := 0
+	for range sh.Commands() {
+		n++
+	}
+	if want := 22 + 4*100; n != want {
+		t.Errorf("%d commands, want %d", n, want)
+	}
+}
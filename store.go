@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// HistoryStore persists History entries.
+type HistoryStore interface {
+	// Load returns the stored entries, oldest first.
+	Load() ([]string, error)
+
+	// Append stores a new entry.
+	Append(s string) error
+}
+
+// WithStore loads the history from st when it's created and
+// appends each new entry to it.
+func WithStore(st HistoryStore) HistoryOption {
+	return func(h *History) {
+		h.store = st
+	}
+}
+
+// FileStore is a HistoryStore backed by a file in the format
+// written by History.WriteTo.  Entries are appended to the file
+// as they are added, so it may be shared by several sessions.
+type FileStore struct {
+	Path string
+	Perm fs.FileMode // Perm defaults to 0600
+}
+
+// Load returns the entries in the file.  A missing file has no
+// entries.
+func (fst FileStore) Load() ([]string, error) {
+	f, err := os.Open(fst.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []string
+	_, err = readHistory(f, func(s string) {
+		entries = append(entries, s)
+	})
+
+	return entries, err
+}
+
+// Append appends an entry to the file, creating it if needed.
+func (fst FileStore) Append(s string) error {
+	perm := fst.Perm
+	if perm == 0 {
+		perm = 0o600
+	}
+
+	f, err := os.OpenFile(fst.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, escapeHistory(s)+"\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ReadFrom adds the entries read from r, one per line, as
+// written by WriteTo.  The entries are not appended to the
+// store.
+func (h *History) ReadFrom(r io.Reader) (int64, error) {
+	return readHistory(r, func(s string) {
+		h.add(HistoryEntry{Line: s})
+	})
+}
+
+// WriteTo writes the entries to w, one per line.  Backslashes
+// and embedded line breaks are escaped with a backslash.
+func (h *History) WriteTo(w io.Writer) (n int64, err error) {
+	bw := bufio.NewWriter(w)
+	for _, e := range h.entries {
+		var nn int
+		nn, err = bw.WriteString(escapeHistory(e.Line) + "\n")
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+	}
+	err = bw.Flush()
+
+	return
+}
+
+// historyEscaper escapes entries so each occupies one line.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// escapeHistory escapes an entry for writing on a single line.
+func escapeHistory(s string) string {
+	return historyEscaper.Replace(s)
+}
+
+// unescapeHistory reverses escapeHistory.  Unknown escapes are
+// kept as is.
+func unescapeHistory(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case '\\':
+			buf.WriteByte('\\')
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(s[i])
+		}
+	}
+
+	return buf.String()
+}
+
+// readHistory reads escaped entries, one per line, from r and
+// calls f with each.  It returns the number of bytes read.
+func readHistory(r io.Reader, f func(string)) (int64, error) {
+	cr := &countReader{r: r}
+	sc := bufio.NewScanner(cr)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		f(unescapeHistory(sc.Text()))
+	}
+
+	return cr.n, sc.Err()
+}
+
+// countReader counts the bytes read from r.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
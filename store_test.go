@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryReadWrite(t *testing.T) {
+	entries := []string{"date", "note add \"line 1\nline 2\"", `echo C:\path\n`, "x\ry"}
+
+	h := NewHistory(0)
+	for _, s := range entries {
+		h.Add(s)
+	}
+
+	var buf bytes.Buffer
+	n, err := h.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo error: %v", err)
+	}
+	want := "date\nnote add \"line 1\\nline 2\"\necho C:\\\\path\\\\n\nx\\ry\n"
+	if buf.String() != want || n != int64(len(want)) {
+		t.Errorf("WriteTo = %d %q, want %d %q", n, buf.String(), len(want), want)
+	}
+
+	h = NewHistory(0)
+	n, err = h.ReadFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrom error: %v", err)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("ReadFrom = %d, want %d", n, len(want))
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if got := h.Prev(); got != entries[i] {
+			t.Errorf("entry[%d] = %q, want %q", i, got, entries[i])
+		}
+	}
+}
+
+func TestUnescapeHistory(t *testing.T) {
+	for s, want := range map[string]string{
+		`plain`:  "plain",
+		`a\nb`:   "a\nb",
+		`a\\nb`:  `a\nb`,
+		`a\tb`:   `a\tb`,
+		`trail\`: `trail\`,
+	} {
+		if got := unescapeHistory(s); got != want {
+			t.Errorf("unescapeHistory(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	st := FileStore{Path: filepath.Join(t.TempDir(), "history")}
+
+	h := NewHistory(3, WithStore(st))
+	if h.Err() != nil {
+		t.Fatalf("missing file error: %v", h.Err())
+	}
+	for _, s := range []string{"a", "b\nc", "b\nc", "d", "e"} {
+		h.Add(s)
+	}
+
+	b, err := os.ReadFile(st.Path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if want := "a\nb\\nc\nd\ne\n"; string(b) != want {
+		t.Errorf("file = %q, want %q", b, want)
+	}
+
+	// A new history loads the stored entries, keeping the max.
+	h = NewHistory(3, WithStore(st))
+	if h.Err() != nil {
+		t.Fatalf("Load error: %v", h.Err())
+	}
+	if h.Len() != 3 {
+		t.Errorf("Len() = %d, want %d", h.Len(), 3)
+	}
+	for _, want := range []string{"e", "d", "b\nc"} {
+		if got := h.Prev(); got != want {
+			t.Errorf("Prev() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestFileStoreErr(t *testing.T) {
+	st := FileStore{Path: filepath.Join(t.TempDir(), "missing", "history")}
+
+	h := NewHistory(0, WithStore(st))
+	if h.Err() != nil {
+		t.Fatalf("missing file error: %v", h.Err())
+	}
+
+	h.Add("date")
+	if h.Err() == nil {
+		t.Error("Append to a missing directory did not set Err")
+	}
+	if got := h.Prev(); got != "date" {
+		t.Errorf("Prev() = %q, want %q", got, "date")
+	}
+}
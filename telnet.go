@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/ebarkie/textcmd/internal/telnet"
+)
+
+// TelnetServer serves an interactive Session to each telnet client that
+// connects.  Each connection has its own line editor and History and the
+// context passed to commands is canceled when the client disconnects.
+type TelnetServer struct {
+	Shell       *Shell
+	Prompt      string // Prompt defaults to DefaultPrompt
+	HistorySize int    // HistorySize defaults to DefaultHistorySize
+
+	// History optionally shares one history between all sessions,
+	// in which case HistorySize is ignored.
+	History *SharedHistory
+
+	// Session optionally configures each new session before it runs,
+	// for example to print a banner.
+	Session func(*Session)
+}
+
+// ListenAndServe listens on the TCP network address addr and serves
+// telnet clients until ctx is done.
+func (srv *TelnetServer) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return srv.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln and serves telnet clients until ctx is
+// done, at which point ln and all of the connections are closed.
+func (srv *TelnetServer) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		wg.Go(func() { srv.serveConn(ctx, c) })
+	}
+}
+
+// serveConn runs a session for a single connection.
+func (srv *TelnetServer) serveConn(ctx context.Context, c net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	context.AfterFunc(ctx, func() { c.Close() })
+
+	tc := telnet.NewConn(c)
+	if tc.Negotiate() != nil {
+		return
+	}
+
+	s := NewSession(srv.Shell, telnetTerm{Conn: tc, cancel: cancel})
+	s.ID = c.RemoteAddr().String()
+	if srv.Prompt != "" {
+		s.Prompt = srv.Prompt
+	}
+	if srv.History != nil {
+		s.History = srv.History.History()
+	} else if srv.HistorySize != 0 {
+		s.History = NewHistory(srv.HistorySize)
+	}
+	if srv.Session != nil {
+		srv.Session(s)
+	}
+
+	s.Run(ctx)
+}
+
+// telnetTerm is the terminal of a telnet session.  A read error, such as
+// the client disconnecting, cancels the session's context.  The session
+// reads while commands run so this also cancels a running command.
+type telnetTerm struct {
+	*telnet.Conn
+	cancel context.CancelFunc
+}
+
+func (t telnetTerm) Read(p []byte) (int, error) {
+	n, err := t.Conn.Read(p)
+	if err != nil {
+		t.cancel()
+	}
+
+	return n, err
+}
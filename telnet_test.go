@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Eric Barkie. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package textcmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startTelnet(t *testing.T, sh *Shell) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		srv := &TelnetServer{Shell: sh, Prompt: "$ "}
+		done <- srv.Serve(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Serve error: %v", err)
+		}
+	})
+
+	return ln.Addr().String()
+}
+
+func TestTelnetServer(t *testing.T) {
+	var sh Shell
+	sh.Register(func(ctx context.Context, rw io.ReadWriter, _ ...string) error {
+		fmt.Fprintf(rw, "width %d\n", Width(ctx))
+		return nil
+	}, "width")
+	sh.Register(func(context.Context, io.ReadWriter, ...string) error { return ErrCmdQuit }, "quit")
+
+	c, err := net.Dial("tcp", startTelnet(t, &sh))
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer c.Close()
+
+	// Report a 100 column window and run commands.
+	c.Write([]byte{255, 250, 31, 0, 100, 0, 24, 255, 240})
+	c.Write([]byte("wid\t\r\x00quit\r\n"))
+
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	out, err := io.ReadAll(bufio.NewReader(c))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if !strings.Contains(string(out), "\r\nwidth 100\r\n$ quit\r\n") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestTelnetDisconnect(t *testing.T) {
+	var sh Shell
+	started, canceled := make(chan bool), make(chan bool)
+	sh.Register(func(ctx context.Context, _ io.ReadWriter, _ ...string) error {
+		started <- true
+		<-ctx.Done()
+		canceled <- true
+		return ctx.Err()
+	}, "watch")
+
+	c, err := net.Dial("tcp", startTelnet(t, &sh))
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	c.Write([]byte("watch\r\n"))
+	<-started
+	c.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command context not canceled on disconnect")
+	}
+}